@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/config"
+	"github.com/hellomyzn/yt-notifier/internal/service"
+)
+
+// runDeadLetter inspects the dead-letter queue of failed notifications:
+//
+//	go run ./cmd/job deadletter list
+//	go run ./cmd/job deadletter retry VIDEO_ID | -all
+//	go run ./cmd/job deadletter drop VIDEO_ID
+func runDeadLetter(cfg *config.AppConfig, root string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("deadletter: expected list, retry or drop")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("deadletter "+action, flag.ContinueOnError)
+	all := fs.Bool("all", false, "retry every dead letter")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	destinations, err := loadDestinations(cfg, root)
+	if err != nil {
+		return err
+	}
+	store, err := openStores(cfg, root, cfg.Storage.Backend)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	pool, err := newHTTPPool(cfg)
+	if err != nil {
+		return err
+	}
+	svc := service.NewNotifyService(store.notified, store.outbox, destinations,
+		time.Duration(cfg.RateLimit.PostSleepMS)*time.Millisecond,
+		pool.Client(config.HTTPTimeout(cfg.HTTP.WebhookTimeoutMS), nil))
+
+	switch action {
+	case "list":
+		entries, err := svc.ListDeadLetters()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			next := "manual"
+			if !e.NextRetryAt.IsZero() {
+				next = e.NextRetryAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\tfailures=%d attempts=%d next_retry=%s\t%s\n",
+				e.Video.VideoID, e.Category, e.Video.Title, e.Failures, e.Attempts, next, e.LastError)
+		}
+		log.Printf("%d dead letter(s)", len(entries))
+		return nil
+	case "retry":
+		if *all {
+			entries, err := svc.ListDeadLetters()
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, e := range entries {
+				if err := svc.RetryDeadLetter(e.Video.VideoID); err != nil {
+					log.Printf("retry video=%s: %v", e.Video.VideoID, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("deadletter: %d of %d retries failed", failed, len(entries))
+			}
+			return nil
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("deadletter retry: expected VIDEO_ID or -all")
+		}
+		return svc.RetryDeadLetter(fs.Arg(0))
+	case "drop":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("deadletter drop: expected VIDEO_ID")
+		}
+		return svc.DropDeadLetter(fs.Arg(0))
+	default:
+		return fmt.Errorf("deadletter: unknown action %q (list, retry, drop)", action)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+	_ "time/tzdata" // the quota ledger needs America/Los_Angeles even on hosts without zoneinfo
 
 	"github.com/hellomyzn/yt-notifier/config"
 	"github.com/hellomyzn/yt-notifier/internal/controller"
+	"github.com/hellomyzn/yt-notifier/internal/httpclient"
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/notifier"
 	"github.com/hellomyzn/yt-notifier/internal/repository"
 	"github.com/hellomyzn/yt-notifier/internal/service"
 )
@@ -25,85 +29,401 @@ func main() {
 		log.Fatal(err)
 	}
 
-	webhookFile := cfg.WebhookFile
-	if webhookFile == "" {
-		webhookFile = filepath.Join("config", "webhooks.env")
-	}
-	if !filepath.IsAbs(webhookFile) {
-		webhookFile = filepath.Join(root, webhookFile)
+	if len(os.Args) > 1 {
+		var err error
+		switch cmd := os.Args[1]; cmd {
+		case "migrate":
+			err = runMigrate(cfg, root, os.Args[2:])
+		case "deadletter":
+			err = runDeadLetter(cfg, root, os.Args[2:])
+		case "serve":
+			err = runServe(cfg, root, os.Args[2:])
+		case "seed":
+			err = runSeed(cfg, root, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown subcommand %q (available: migrate, deadletter, serve, seed)", cmd)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	webhookSecrets, err := config.LoadWebhookFile(webhookFile)
+	runJob(cfg, root)
+}
+
+// runJob is the scheduled RunOnce job: fetch every enabled channel and notify new videos.
+func runJob(cfg *config.AppConfig, root string) {
+	a, err := buildApp(cfg, root)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer a.Close()
 
-	categoryToWebhook := map[string]string{}
-	for category, envName := range cfg.CategoryToEnv {
-		if envName == "" {
-			continue
-		}
-		webhook, ok := webhookSecrets[envName]
-		if !ok || webhook == "" {
-			log.Fatalf("webhook secret not found for %s", envName)
-		}
-		categoryToWebhook[category] = webhook
+	if err := a.job.RunOnce(); err != nil {
+		log.Fatal(err)
 	}
+	a.logAPIUsage(cfg)
+}
 
-	csvDir := filepath.Join(root, "src", "csv")
-	chRepo := &repository.CSVChannelRepository{Path: filepath.Join(csvDir, "channels.csv")}
-	notiRepo := &repository.CSVNotifiedRepository{Path: filepath.Join(csvDir, "notified.csv")}
-	feedRepo := &repository.RSSFeedRepository{}
+// app holds the repositories and services shared by the job and the serve mode.
+type app struct {
+	store     *stores
+	pool      *httpclient.Pool
+	ledger    *repository.CSVQuotaLedger
+	ytRepo    *repository.YouTubeAPIRepository
+	resolver  *repository.ResolvingChannelRepository
+	channels  repository.ChannelRepository
+	feedSvc   service.FeedService
+	notifySvc service.NotifyService
+	job       controller.JobController
+}
 
-	ytKey := ""
-	ytCfg := cfg.YouTube
-	if ytCfg.APIKeyFile != "" && ytCfg.APIKeyName != "" {
-		ytFile := ytCfg.APIKeyFile
-		if !filepath.IsAbs(ytFile) {
-			ytFile = filepath.Join(root, ytFile)
-		}
-		secrets, err := config.LoadEnvFile(ytFile)
+// Close saves quota charges made after the last feed Commit, e.g. by pushes in serve mode.
+func (a *app) Close() error {
+	if err := a.ledger.Commit(); err != nil {
+		log.Printf("failed to save youtube quota ledger: %v", err)
+	}
+	return a.store.Close()
+}
+
+func buildApp(cfg *config.AppConfig, root string) (*app, error) {
+	categoryToDestination, err := loadDestinations(cfg, root)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := openStores(cfg, root, cfg.Storage.Backend)
+	if err != nil {
+		return nil, err
+	}
+	a := &app{store: store}
+	notiRepo := store.notified
+	a.pool, err = newHTTPPool(cfg)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	// One limiter shared by every fetch worker keeps the per-host request pace regardless of concurrency.
+	limiter := httpclient.NewHostRateLimiter(cfg.FetchHostInterval())
+	feedRepo := &repository.RSSFeedRepository{
+		Client:  a.pool.Client(config.HTTPTimeout(cfg.HTTP.FeedTimeoutMS), limiter),
+		BaseURL: cfg.HTTP.FeedBaseURL,
+	}
+
+	ytKeys, err := loadYouTubeKeys(cfg, root)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	a.ledger, err = repository.NewCSVQuotaLedger(filepath.Join(csvDir(cfg, root), "quota_ledger.csv"))
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	a.ytRepo = repository.NewYouTubeAPIRepository(ytKeys)
+	if a.ytRepo != nil {
+		a.ytRepo.Client = a.pool.Client(config.HTTPTimeout(cfg.HTTP.APITimeoutMS), limiter)
+		a.ytRepo.BaseURL = cfg.HTTP.APIBaseURL
+		a.ytRepo.Ledger = a.ledger
+	}
+	// Keep the interface nil without a key so the feed service knows the API is unavailable.
+	var (
+		ytSource   repository.YouTubeRepository
+		ytResolver repository.ChannelIDResolver
+	)
+	if a.ytRepo != nil {
+		ytSource = a.ytRepo
+		ytResolver = a.ytRepo
+	}
+
+	validatorRepo, err := repository.NewCSVFeedValidatorRepository(filepath.Join(csvDir(cfg, root), "feed_validators.csv"))
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	firstRun := service.FirstRunMode(cfg.FirstRun)
+	switch firstRun {
+	case "":
+		firstRun = service.FirstRunSeed
+	case service.FirstRunSeed, service.FirstRunNewest, service.FirstRunNotifyAll:
+	default:
+		store.Close()
+		return nil, fmt.Errorf("unknown first_run %q (seed, newest or all)", cfg.FirstRun)
+	}
+
+	unknownDuration := service.UnknownDurationMode(cfg.Filters.UnknownDuration)
+	switch unknownDuration {
+	case "":
+		unknownDuration = service.UnknownDurationPass
+	case service.UnknownDurationPass, service.UnknownDurationHold:
+	default:
+		store.Close()
+		return nil, fmt.Errorf("unknown filters.unknown_duration %q (pass or hold)", cfg.Filters.UnknownDuration)
+	}
+
+	maxAge, err := service.ParseMaxAge(cfg.Filters.MaxAge)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	categoryMaxAge := map[string]service.MaxAge{}
+	for category, raw := range cfg.CategoryMaxAge {
+		limit, err := service.ParseMaxAge(raw)
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				log.Printf("youtube api key file %s not found; falling back to RSS", ytFile)
-			} else {
-				log.Fatalf("failed to load youtube api key file: %v", err)
-			}
-		} else {
-			ytKey = secrets[ytCfg.APIKeyName]
-			if ytKey == "" {
-				log.Printf("youtube api key %s not found in %s; falling back to RSS", ytCfg.APIKeyName, ytFile)
+			store.Close()
+			return nil, fmt.Errorf("category_max_age.%s: %w", category, err)
+		}
+		categoryMaxAge[category] = limit
+	}
+	languageRouting := service.LanguageRouting{Categories: map[string]bool{}, Fallback: service.Language(cfg.LanguageRouting.Fallback)}
+	switch languageRouting.Fallback {
+	case "":
+		languageRouting.Fallback = service.LanguageJP
+	case service.LanguageJP, service.LanguageEN:
+	default:
+		store.Close()
+		return nil, fmt.Errorf("unknown language_routing.fallback %q (jp or en)", cfg.LanguageRouting.Fallback)
+	}
+	for _, base := range cfg.LanguageRouting.Categories {
+		for _, lang := range []service.Language{service.LanguageJP, service.LanguageEN} {
+			if _, ok := categoryToDestination[base+"_"+string(lang)]; !ok {
+				store.Close()
+				return nil, fmt.Errorf("language_routing: category %s_%s has no webhook", base, lang)
 			}
 		}
+		languageRouting.Categories[base] = true
+	}
+	// A route may target a mapped category or a base category that language routing splits.
+	routable := func(category string) bool {
+		_, ok := categoryToDestination[category]
+		return ok || languageRouting.Categories[category]
+	}
+	categoryRoutes := map[string][]service.Route{}
+	for category, raw := range cfg.CategoryRoutes {
+		routes, err := service.ParseRoutes(raw)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("category_routes.%s: %w", category, err)
+		}
+		if err := checkRouteTargets(routes, routable); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("category_routes.%s: %w", category, err)
+		}
+		categoryRoutes[category] = routes
+	}
+	categoryRules := map[string]service.RuleSet{}
+	for _, category := range ruleCategories(cfg) {
+		rules, err := service.ParseRuleSet(cfg.CategoryInclude[category], cfg.CategoryExclude[category])
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("rules of category %s: %w", category, err)
+		}
+		categoryRules[category] = rules
+	}
+	a.resolver = &repository.ResolvingChannelRepository{
+		Base:     store.channels,
+		Resolver: ytResolver,
+		Cache:    &repository.CSVChannelIDCache{Path: filepath.Join(csvDir(cfg, root), "channel_ids.csv")},
+	}
+	a.channels = &routeCheckedChannels{base: a.resolver, routable: routable}
+	loc := time.Local
+	if cfg.Timezone != "" {
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("timezone %q: %w", cfg.Timezone, err)
+		}
 	}
-	ytRepo := repository.NewYouTubeAPIRepository(ytKey)
 
-	feedSvc := service.NewFeedService(
-		feedRepo, ytRepo, notiRepo,
-		cfg.Filters.IncludeLive, cfg.Filters.IncludePremieres, cfg.Filters.IncludeShorts,
+	a.feedSvc = service.NewFeedService(
+		feedRepo, ytSource, notiRepo, validatorRepo,
+		service.FeedOptions{
+			IncludeLive:      cfg.Filters.IncludeLive,
+			IncludePremieres: cfg.Filters.IncludePremieres,
+			IncludeShorts:    cfg.Filters.IncludeShorts,
+			Retry: service.RetryPolicy{
+				MaxRetries: cfg.RateLimit.FetchMaxRetries,
+				BaseDelay:  time.Duration(cfg.RateLimit.FetchRetryBaseMS) * time.Millisecond,
+				MaxDelay:   time.Duration(cfg.RateLimit.FetchRetryMaxMS) * time.Millisecond,
+			},
+			Quota: service.QuotaBudget{
+				Ledger:     a.ledger,
+				DailyQuota: cfg.YouTube.DailyQuota,
+				Reserve:    cfg.YouTube.QuotaReserve,
+			},
+			FirstRun:            firstRun,
+			MaxAge:              maxAge,
+			CategoryMaxAge:      categoryMaxAge,
+			Location:            loc,
+			CategoryRules:       categoryRules,
+			CategoryFilters:     cfg.CategoryFilter,
+			ChannelFilters:      cfg.ChannelFilter,
+			MinDuration:         cfg.Filters.MinDuration,
+			MaxDuration:         cfg.Filters.MaxDuration,
+			CategoryMinDuration: cfg.CategoryMinDuration,
+			CategoryMaxDuration: cfg.CategoryMaxDuration,
+			UnknownDuration:     unknownDuration,
+			CategoryRoutes:      categoryRoutes,
+			LanguageRouting:     languageRouting,
+		},
 	)
 
-	notifySvc := service.NewNotifyService(
+	a.notifySvc = service.NewNotifyService(
 		notiRepo,
-		categoryToWebhook,
+		store.outbox,
+		categoryToDestination,
 		time.Duration(cfg.RateLimit.PostSleepMS)*time.Millisecond,
+		a.pool.Client(config.HTTPTimeout(cfg.HTTP.WebhookTimeoutMS), nil),
 	)
 
-	job := controller.NewJobController(
-		chRepo,
-		feedSvc,
-		notifySvc,
-		time.Duration(cfg.RateLimit.FetchSleepMS)*time.Millisecond,
+	a.job = controller.NewJobController(
+		a.channels,
+		a.feedSvc,
+		a.notifySvc,
+		cfg.RateLimit.FetchWorkers,
 	)
+	return a, nil
+}
 
-	if err := job.RunOnce(); err != nil {
-		log.Fatal(err)
+// loadYouTubeKeys reads the configured key names from youtube.env. A missing file or key only
+// disables the API.
+func loadYouTubeKeys(cfg *config.AppConfig, root string) ([]repository.APIKey, error) {
+	keyNames := cfg.YouTubeKeyNames()
+	if cfg.YouTube.APIKeyFile == "" || len(keyNames) == 0 {
+		return nil, nil
+	}
+	ytFile := cfg.YouTube.APIKeyFile
+	if !filepath.IsAbs(ytFile) {
+		ytFile = filepath.Join(root, ytFile)
+	}
+	secrets, err := config.LoadEnvFile(ytFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("youtube api key file %s not found; falling back to RSS", ytFile)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load youtube api key file: %w", err)
+	}
+	var keys []repository.APIKey
+	for _, name := range keyNames {
+		if secrets[name] == "" {
+			log.Printf("youtube api key %s not found in %s", name, ytFile)
+			continue
+		}
+		keys = append(keys, repository.APIKey{Name: name, Value: secrets[name]})
+	}
+	if len(keys) == 0 {
+		log.Printf("no youtube api key found in %s; falling back to RSS", ytFile)
+	}
+	return keys, nil
+}
+
+func (a *app) logAPIUsage(cfg *config.AppConfig) {
+	if a.ytRepo == nil {
+		return
+	}
+	if metrics := a.ytRepo.Metrics(); metrics.Requests > 0 || metrics.QuotaUnits > 0 {
+		log.Printf("youtube api usage: requests=%d quota_units=%d by_endpoint=%v", metrics.Requests, metrics.QuotaUnits, metrics.ByEndpoint)
+	}
+	for _, key := range a.ytRepo.KeyUsages() {
+		status := key.Status
+		if status == "" {
+			status = "ok"
+		}
+		log.Printf("youtube api key %s: requests=%d quota_units=%d status=%s", key.Name, key.Requests, key.QuotaUnits, status)
+	}
+	if usage, err := a.ledger.Today(); err == nil {
+		log.Printf("youtube quota today (%s pacific): %d/%d units by_endpoint=%v", usage.Day, usage.Units, cfg.YouTube.DailyQuota, usage.ByEndpoint)
+	}
+}
+
+// loadDestinations maps every category in category_to_env to its output and webhook URL.
+func loadDestinations(cfg *config.AppConfig, root string) (map[string]service.Destination, error) {
+	webhookSecrets, err := config.LoadWebhookFile(resolvePath(root, cfg.WebhookFile, filepath.Join("config", "webhooks.env")))
+	if err != nil {
+		return nil, err
+	}
+
+	categoryToDestination := map[string]service.Destination{}
+	for category, envName := range cfg.CategoryToEnv {
+		if envName == "" {
+			continue
+		}
+		webhook, ok := webhookSecrets[envName]
+		if !ok || webhook == "" {
+			return nil, fmt.Errorf("webhook secret not found for %s", envName)
+		}
+		output := cfg.OutputFor(category)
+		if output != notifier.OutputDiscord && output != notifier.OutputSlack {
+			return nil, fmt.Errorf("unknown output %q for category %s", output, category)
+		}
+		webhook, err = httpclient.Rebase(webhook, cfg.HTTP.WebhookBaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("webhook for %s: %w", envName, err)
+		}
+		categoryToDestination[category] = service.Destination{Output: output, Webhook: webhook}
+	}
+	return categoryToDestination, nil
+}
+
+// ruleCategories lists the categories that have include or exclude rules.
+func ruleCategories(cfg *config.AppConfig) []string {
+	var out []string
+	for category := range cfg.CategoryInclude {
+		out = append(out, category)
+	}
+	for category := range cfg.CategoryExclude {
+		if _, ok := cfg.CategoryInclude[category]; !ok {
+			out = append(out, category)
+		}
 	}
+	return out
+}
+
+// checkRouteTargets reports the first route whose category has nowhere to be delivered.
+func checkRouteTargets(routes []service.Route, routable func(category string) bool) error {
+	for _, r := range routes {
+		if !routable(r.Category) {
+			return fmt.Errorf("category %s has no webhook", r.Category)
+		}
+	}
+	return nil
+}
+
+// routeCheckedChannels leaves out channels whose routes column cannot be parsed or targets a
+// category without a webhook, since every video it routes would fail before reaching the outbox.
+type routeCheckedChannels struct {
+	base     repository.ChannelRepository
+	routable func(category string) bool
+}
 
-	if ytRepo != nil {
-		if metrics := ytRepo.Metrics(); metrics.Requests > 0 || metrics.QuotaUnits > 0 {
-			log.Printf("youtube api usage: requests=%d quota_units=%d", metrics.Requests, metrics.QuotaUnits)
+func (r *routeCheckedChannels) ListEnabled() ([]model.ChannelDTO, error) {
+	channels, err := r.base.ListEnabled()
+	if err != nil {
+		return nil, err
+	}
+	out := channels[:0]
+	for _, ch := range channels {
+		routes, err := service.ParseRoutes(ch.Routes)
+		if err == nil {
+			err = checkRouteTargets(routes, r.routable)
 		}
+		if err != nil {
+			log.Printf("skipping channel=%s (%s): routes: %v", ch.ChannelID, ch.Name, err)
+			continue
+		}
+		out = append(out, ch)
 	}
+	return out, nil
+}
+
+// newHTTPPool builds the transport shared by the feed, API and webhook clients from the http section.
+func newHTTPPool(cfg *config.AppConfig) (*httpclient.Pool, error) {
+	return httpclient.NewPool(httpclient.Config{
+		ProxyURL:            cfg.HTTP.Proxy,
+		UserAgent:           cfg.HTTP.UserAgent,
+		MaxIdleConnsPerHost: cfg.HTTP.MaxIdleConnsPerHost,
+	})
 }
 
 func repoRoot() (string, error) {
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/hellomyzn/yt-notifier/config"
+	"github.com/hellomyzn/yt-notifier/internal/service"
+)
+
+// runMigrate copies channels, history and the outbox between storage backends:
+//
+//	go run ./cmd/job migrate -from csv -to sqlite [-dry-run]
+func runMigrate(cfg *config.AppConfig, root string, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := fs.String("from", backendCSV, "source backend (csv, sqlite, jsonl)")
+	to := fs.String("to", "", "target backend (csv, sqlite, jsonl)")
+	dryRun := fs.Bool("dry-run", false, "report what would be copied without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return fmt.Errorf("migrate: -to is required")
+	}
+	if *from == *to {
+		return fmt.Errorf("migrate: source and target are both %q", *from)
+	}
+
+	src, err := openStores(cfg, root, *from)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", *from, err)
+	}
+	defer src.Close()
+	dst, err := openStores(cfg, root, *to)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", *to, err)
+	}
+	defer dst.Close()
+
+	svc := service.NewMigrateService(src.channels, src.notified, src.outbox, dst.channels, dst.notified, dst.outbox)
+	report, err := svc.Migrate(*dryRun)
+	for _, c := range report.ChannelConflicts {
+		log.Printf("conflict: %s", c)
+	}
+	for _, c := range report.NotifiedConflicts {
+		log.Printf("conflict: %s", c)
+	}
+	for _, c := range report.OutboxConflicts {
+		log.Printf("conflict: %s", c)
+	}
+	log.Printf("migrate %s -> %s (dry_run=%t): channels read=%d copied=%d skipped=%d conflicts=%d target=%d; notified read=%d copied=%d skipped=%d conflicts=%d target=%d; outbox read=%d copied=%d skipped=%d conflicts=%d target=%d",
+		*from, *to, *dryRun,
+		report.ChannelsRead, report.ChannelsCopied, report.ChannelsSkipped, len(report.ChannelConflicts), report.ChannelsInTarget,
+		report.NotifiedRead, report.NotifiedCopied, report.NotifiedSkipped, len(report.NotifiedConflicts), report.NotifiedInTarget,
+		report.OutboxRead, report.OutboxCopied, report.OutboxSkipped, len(report.OutboxConflicts), report.OutboxInTarget)
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hellomyzn/yt-notifier/config"
+)
+
+// runSeed records the current videos of the selected channels as seen without notifying them.
+func runSeed(cfg *config.AppConfig, root string, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	channelRef := fs.String("channel", "", "channel to seed: ID, @handle or channel URL as in channels.csv")
+	category := fs.String("category", "", "seed every enabled channel of this category")
+	newest := fs.Bool("newest", false, "notify the newest video instead of recording it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*channelRef == "") == (*category == "") {
+		return fmt.Errorf("seed: pass exactly one of -channel or -category")
+	}
+
+	a, err := buildApp(cfg, root)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// Match on the resolved ID so the channel can be given in whatever form channels.csv uses.
+	var channelID string
+	if *channelRef != "" {
+		if channelID, err = a.resolver.Resolve(*channelRef); err != nil {
+			return fmt.Errorf("seed: channel %q: %w", *channelRef, err)
+		}
+	}
+	channels, err := a.channels.ListEnabled()
+	if err != nil {
+		return err
+	}
+	matched := 0
+	for _, ch := range channels {
+		if channelID != "" && ch.ChannelID != channelID {
+			continue
+		}
+		if *category != "" && !strings.EqualFold(ch.Category, *category) {
+			continue
+		}
+		matched++
+		kept, err := a.feedSvc.Seed(ch, *newest)
+		if err != nil {
+			log.Printf("failed to seed channel=%s: %v", ch.ChannelID, err)
+			continue
+		}
+		for _, v := range kept {
+			if err := a.notifySvc.Notify(v.Category, v); err != nil {
+				log.Printf("failed to notify channel=%s video=%s: %v", ch.ChannelID, v.VideoID, err)
+			}
+		}
+	}
+	if matched == 0 {
+		return fmt.Errorf("seed: no enabled channel matches")
+	}
+	if err := a.feedSvc.Commit(); err != nil {
+		return err
+	}
+	log.Printf("seeded %d channels: %d videos recorded without notification", matched, a.feedSvc.Stats().Seeded)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/config"
+	"github.com/hellomyzn/yt-notifier/internal/controller"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+	"github.com/hellomyzn/yt-notifier/internal/service"
+)
+
+// websubSyncInterval is how often subscriptions are checked for renewal, retry or removal.
+const websubSyncInterval = time.Minute
+
+// runServe is the long-running mode: it receives WebSub pushes and polls only the channels
+// whose subscription is not active.
+func runServe(cfg *config.AppConfig, root string, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", cfg.WebSub.ListenAddr, "listen address for the websub callback")
+	callback := fs.String("callback", cfg.WebSub.CallbackURL, "public callback URL registered with the hub")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *callback == "" {
+		return fmt.Errorf("serve: websub.callback_url (or -callback) is required")
+	}
+
+	// Without a secret anyone who can reach the callback could push content to be notified.
+	if cfg.WebSub.SecretFile == "" || cfg.WebSub.SecretName == "" {
+		return fmt.Errorf("serve: websub.secret_file and websub.secret_name are required to verify pushed content")
+	}
+	secrets, err := config.LoadEnvFile(resolvePath(root, cfg.WebSub.SecretFile, ""))
+	if err != nil {
+		return fmt.Errorf("failed to load websub secret file: %w", err)
+	}
+	secret := secrets[cfg.WebSub.SecretName]
+	if secret == "" {
+		return fmt.Errorf("websub secret %s not found", cfg.WebSub.SecretName)
+	}
+
+	a, err := buildApp(cfg, root)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	hub := &repository.HTTPWebSubHub{
+		HubURL: cfg.WebSub.HubURL,
+		Client: a.pool.Client(config.HTTPTimeout(cfg.HTTP.FeedTimeoutMS), nil),
+	}
+	ws := service.NewWebSubService(hub, a.feedSvc, a.notifySvc, a.store.notified, service.WebSubOptions{
+		Callback:      *callback,
+		FeedBaseURL:   cfg.HTTP.FeedBaseURL,
+		Secret:        secret,
+		Lease:         time.Duration(cfg.WebSub.LeaseSeconds) * time.Second,
+		RenewBefore:   time.Duration(cfg.WebSub.RenewBeforeMinutes) * time.Minute,
+		VerifyTimeout: time.Duration(cfg.WebSub.VerifyTimeoutMin) * time.Minute,
+		RetryInterval: time.Duration(cfg.WebSub.PollIntervalMinutes) * time.Minute,
+		PushMaxAge:    time.Duration(cfg.WebSub.PushMaxAgeHours) * time.Hour,
+	})
+	ctrl := controller.NewWebSubController(a.channels, a.job, ws, websubSyncInterval,
+		time.Duration(cfg.WebSub.PollIntervalMinutes)*time.Minute)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: *addr, Handler: ctrl, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("websub callback listening on %s (public %s)", *addr, *callback)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- ctrl.Run(ctx) }()
+
+	// Run returns once ctx is cancelled by a signal; a listener failure cancels it as well.
+	select {
+	case err = <-serveErr:
+		stop()
+		<-runErr
+	case err = <-runErr:
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	a.logAPIUsage(cfg)
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"github.com/hellomyzn/yt-notifier/config"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+const (
+	backendCSV    = "csv"
+	backendSQLite = "sqlite"
+	backendJSONL  = "jsonl"
+)
+
+// stores bundles the channel and history repositories of one storage backend.
+type stores struct {
+	channels repository.ChannelStore
+	notified repository.NotifiedStore
+	outbox   repository.OutboxRepository
+	closer   io.Closer
+}
+
+func (s *stores) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+func csvDir(cfg *config.AppConfig, root string) string {
+	return resolvePath(root, cfg.Storage.CSVDir, filepath.Join("src", "csv"))
+}
+
+func resolvePath(root, path, fallback string) string {
+	if path == "" {
+		path = fallback
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return path
+}
+
+func openStores(cfg *config.AppConfig, root, backend string) (*stores, error) {
+	switch backend {
+	case "", backendCSV:
+		dir := csvDir(cfg, root)
+		notified, err := repository.NewCSVNotifiedRepository(filepath.Join(dir, "notified.csv"))
+		if err != nil {
+			return nil, err
+		}
+		if report := notified.Report(); report.Duplicates > 0 || report.Malformed > 0 {
+			log.Printf("notified.csv: rows=%d duplicates=%d (lines %v) malformed=%d (lines %v)",
+				report.Rows, report.Duplicates, report.DuplicateLines, report.Malformed, report.MalformedLines)
+		}
+		outbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		return &stores{
+			channels: &repository.CSVChannelRepository{Path: filepath.Join(dir, "channels.csv")},
+			notified: notified,
+			outbox:   outbox,
+		}, nil
+	case backendSQLite:
+		db, err := repository.OpenSQLite(resolvePath(root, cfg.Storage.SQLitePath, filepath.Join("src", "csv", "yt-notifier.db")))
+		if err != nil {
+			return nil, err
+		}
+		return &stores{
+			channels: &repository.SQLiteChannelRepository{DB: db},
+			notified: &repository.SQLiteNotifiedRepository{DB: db},
+			outbox:   &repository.SQLiteOutboxRepository{DB: db},
+			closer:   db,
+		}, nil
+	case backendJSONL:
+		dir := csvDir(cfg, root)
+		notified, err := repository.NewJSONLNotifiedRepository(filepath.Join(dir, "notified.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		outbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		return &stores{
+			channels: &repository.JSONLChannelRepository{Path: filepath.Join(dir, "channels.jsonl")},
+			notified: notified,
+			outbox:   outbox,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/expr"
 )
 
 type AppConfig struct {
@@ -13,18 +16,77 @@ type AppConfig struct {
 	CategoryToOutput map[string]string
 	CategoryToEnv    map[string]string
 	WebhookFile      string
+	FirstRun         string // seed (default), newest or all
 	YouTube          struct {
-		APIKeyFile string
-		APIKeyName string
+		APIKeyFile   string
+		APIKeyName   string
+		APIKeyNames  []string // rotation order; api_key_name is used when empty
+		DailyQuota   int      // Data API units per Pacific day; 0 disables the budget
+		QuotaReserve int      // units kept back for manual use before degrading to RSS
 	}
 	RateLimit struct {
-		FetchSleepMS int
-		PostSleepMS  int
+		FetchSleepMS        int
+		FetchWorkers        int
+		FetchHostIntervalMS int
+		PostSleepMS         int
+		FetchMaxRetries     int // transient RSS / API errors; 0 disables retries
+		FetchRetryBaseMS    int
+		FetchRetryMaxMS     int
 	}
 	Filters struct {
 		IncludePremieres bool
 		IncludeLive      bool
 		IncludeShorts    bool
+		MaxAge           string // e.g. 72h, or 3d for calendar days in Timezone
+		MinDuration      time.Duration
+		MaxDuration      time.Duration
+		UnknownDuration  string // pass (default) or hold, for videos whose duration and other API fields are not known
+	}
+	CategoryMaxAge map[string]string // overrides filters.max_age per category
+	// CategoryMinDuration and CategoryMaxDuration override filters.min_duration / max_duration.
+	CategoryMinDuration map[string]time.Duration
+	CategoryMaxDuration map[string]time.Duration
+	// CategoryInclude and CategoryExclude hold include/exclude rule lists per category; see
+	// service.ParseRules for the syntax.
+	CategoryInclude map[string]string
+	CategoryExclude map[string]string
+	// CategoryRoutes holds "category=rule" route lists by channel category ("*" for any).
+	CategoryRoutes map[string]string
+	// LanguageRouting splits base categories into <base>_jp / <base>_en per video.
+	LanguageRouting struct {
+		Categories []string // lowercased base categories
+		Fallback   string   // jp or en, when the language cannot be told
+	}
+	// CategoryFilter and ChannelFilter (keyed by channel ID) are filter expressions, compiled here.
+	CategoryFilter map[string]*expr.Program
+	ChannelFilter  map[string]*expr.Program
+	HTTP           struct {
+		UserAgent           string
+		Proxy               string
+		MaxIdleConnsPerHost int
+		FeedTimeoutMS       int
+		APITimeoutMS        int
+		WebhookTimeoutMS    int
+		FeedBaseURL         string // overrides for testing against a local server
+		APIBaseURL          string
+		WebhookBaseURL      string
+	}
+	WebSub struct {
+		HubURL              string
+		CallbackURL         string // public URL the hub posts to
+		ListenAddr          string
+		SecretFile          string
+		SecretName          string
+		LeaseSeconds        int
+		RenewBeforeMinutes  int
+		VerifyTimeoutMin    int
+		PollIntervalMinutes int
+		PushMaxAgeHours     int // pushed entries published earlier are edits of old videos
+	}
+	Storage struct {
+		Backend    string // csv (default) or sqlite
+		CSVDir     string
+		SQLitePath string
 	}
 	Timezone string
 }
@@ -37,13 +99,33 @@ func Load(path string) (*AppConfig, error) {
 	defer f.Close()
 
 	cfg := &AppConfig{
-		CategoryToOutput: map[string]string{},
-		CategoryToEnv:    map[string]string{},
+		CategoryToOutput:    map[string]string{},
+		CategoryToEnv:       map[string]string{},
+		CategoryMaxAge:      map[string]string{},
+		CategoryMinDuration: map[string]time.Duration{},
+		CategoryMaxDuration: map[string]time.Duration{},
+		CategoryInclude:     map[string]string{},
+		CategoryExclude:     map[string]string{},
+		CategoryRoutes:      map[string]string{},
+		CategoryFilter:      map[string]*expr.Program{},
+		ChannelFilter:       map[string]*expr.Program{},
 	}
+	cfg.YouTube.DailyQuota = 10000
+	cfg.WebSub.ListenAddr = ":8080"
+	cfg.WebSub.LeaseSeconds = 5 * 24 * 60 * 60
+	cfg.WebSub.RenewBeforeMinutes = 12 * 60
+	cfg.WebSub.VerifyTimeoutMin = 10
+	cfg.WebSub.PollIntervalMinutes = 30
+	cfg.WebSub.PushMaxAgeHours = 72
+	cfg.RateLimit.FetchMaxRetries = 3
+	cfg.RateLimit.FetchRetryBaseMS = 1000
+	cfg.RateLimit.FetchRetryMaxMS = 30000
 
 	scanner := bufio.NewScanner(f)
 	section := ""
+	line := 0
 	for scanner.Scan() {
+		line++
 		raw := scanner.Text()
 		trimmed := strings.TrimSpace(raw)
 		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
@@ -58,7 +140,7 @@ func Load(path string) (*AppConfig, error) {
 			}
 			section = ""
 			if err := applyTopLevel(cfg, key, value); err != nil {
-				return nil, err
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
 			}
 			continue
 		}
@@ -71,7 +153,7 @@ func Load(path string) (*AppConfig, error) {
 			continue
 		}
 		if err := applySection(cfg, section, key, value); err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -80,6 +162,45 @@ func Load(path string) (*AppConfig, error) {
 	return cfg, nil
 }
 
+// OutputFor returns the output (discord or slack) for a category, falling back to default_output and then discord.
+func (c *AppConfig) OutputFor(category string) string {
+	if out := c.CategoryToOutput[strings.ToLower(category)]; out != "" {
+		return strings.ToLower(out)
+	}
+	if c.DefaultOutput != "" {
+		return strings.ToLower(c.DefaultOutput)
+	}
+	return "discord"
+}
+
+// FetchHostInterval is the minimum gap between two fetches to the same host.
+// fetch_sleep_ms is honoured when fetch_host_interval_ms is not set.
+func (c *AppConfig) FetchHostInterval() time.Duration {
+	if c.RateLimit.FetchHostIntervalMS > 0 {
+		return time.Duration(c.RateLimit.FetchHostIntervalMS) * time.Millisecond
+	}
+	return time.Duration(c.RateLimit.FetchSleepMS) * time.Millisecond
+}
+
+// YouTubeKeyNames returns the youtube.env variable names to try in order.
+func (c *AppConfig) YouTubeKeyNames() []string {
+	if len(c.YouTube.APIKeyNames) > 0 {
+		return c.YouTube.APIKeyNames
+	}
+	if c.YouTube.APIKeyName != "" {
+		return []string{c.YouTube.APIKeyName}
+	}
+	return nil
+}
+
+// HTTPTimeout converts a *_timeout_ms value, using 15s when it is unset.
+func HTTPTimeout(ms int) time.Duration {
+	if ms <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
 func splitKeyValue(line string) (string, string, bool) {
 	idx := strings.Index(line, ":")
 	if idx == -1 {
@@ -87,7 +208,12 @@ func splitKeyValue(line string) (string, string, bool) {
 	}
 	key := strings.TrimSpace(line[:idx])
 	value := strings.TrimSpace(line[idx+1:])
-	if c := strings.Index(value, "#"); c != -1 {
+	if strings.HasPrefix(value, "\"") || strings.HasPrefix(value, "'") {
+		// A quoted value may contain '#' (e.g. in a regex); only text after the closing quote is a comment.
+		if end := strings.Index(value[1:], value[:1]); end != -1 {
+			value = value[:end+2]
+		}
+	} else if c := strings.Index(value, "#"); c != -1 {
 		value = strings.TrimSpace(value[:c])
 	}
 	if value == "" {
@@ -104,6 +230,8 @@ func applyTopLevel(cfg *AppConfig, key, value string) error {
 		cfg.Timezone = value
 	case "webhook_file":
 		cfg.WebhookFile = value
+	case "first_run":
+		cfg.FirstRun = strings.ToLower(value)
 	default:
 		return nil
 	}
@@ -122,6 +250,23 @@ func applySection(cfg *AppConfig, section, key, value string) error {
 			cfg.YouTube.APIKeyFile = value
 		case "api_key_name":
 			cfg.YouTube.APIKeyName = value
+		case "api_key_names":
+			cfg.YouTube.APIKeyNames = nil
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					cfg.YouTube.APIKeyNames = append(cfg.YouTube.APIKeyNames, name)
+				}
+			}
+		case "daily_quota", "quota_reserve":
+			iv, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid int for %s: %w", key, err)
+			}
+			if key == "daily_quota" {
+				cfg.YouTube.DailyQuota = iv
+			} else {
+				cfg.YouTube.QuotaReserve = iv
+			}
 		}
 	case "rate_limit":
 		iv, err := strconv.Atoi(value)
@@ -131,10 +276,146 @@ func applySection(cfg *AppConfig, section, key, value string) error {
 		switch key {
 		case "fetch_sleep_ms":
 			cfg.RateLimit.FetchSleepMS = iv
+		case "fetch_workers":
+			cfg.RateLimit.FetchWorkers = iv
+		case "fetch_host_interval_ms":
+			cfg.RateLimit.FetchHostIntervalMS = iv
 		case "post_sleep_ms":
 			cfg.RateLimit.PostSleepMS = iv
+		case "fetch_max_retries":
+			cfg.RateLimit.FetchMaxRetries = iv
+		case "fetch_retry_base_ms":
+			cfg.RateLimit.FetchRetryBaseMS = iv
+		case "fetch_retry_max_ms":
+			cfg.RateLimit.FetchRetryMaxMS = iv
+		}
+	case "http":
+		switch key {
+		case "user_agent":
+			cfg.HTTP.UserAgent = value
+		case "proxy":
+			cfg.HTTP.Proxy = value
+		case "feed_base_url":
+			cfg.HTTP.FeedBaseURL = value
+		case "api_base_url":
+			cfg.HTTP.APIBaseURL = value
+		case "webhook_base_url":
+			cfg.HTTP.WebhookBaseURL = value
+		case "max_idle_conns_per_host", "feed_timeout_ms", "api_timeout_ms", "webhook_timeout_ms":
+			iv, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid int for %s: %w", key, err)
+			}
+			switch key {
+			case "max_idle_conns_per_host":
+				cfg.HTTP.MaxIdleConnsPerHost = iv
+			case "feed_timeout_ms":
+				cfg.HTTP.FeedTimeoutMS = iv
+			case "api_timeout_ms":
+				cfg.HTTP.APITimeoutMS = iv
+			case "webhook_timeout_ms":
+				cfg.HTTP.WebhookTimeoutMS = iv
+			}
+		}
+	case "websub":
+		switch key {
+		case "hub_url":
+			cfg.WebSub.HubURL = value
+		case "callback_url":
+			cfg.WebSub.CallbackURL = value
+		case "listen_addr":
+			cfg.WebSub.ListenAddr = value
+		case "secret_file":
+			cfg.WebSub.SecretFile = value
+		case "secret_name":
+			cfg.WebSub.SecretName = value
+		case "lease_seconds", "renew_before_minutes", "verify_timeout_minutes", "poll_interval_minutes", "push_max_age_hours":
+			iv, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid int for %s: %w", key, err)
+			}
+			switch key {
+			case "lease_seconds":
+				cfg.WebSub.LeaseSeconds = iv
+			case "renew_before_minutes":
+				cfg.WebSub.RenewBeforeMinutes = iv
+			case "verify_timeout_minutes":
+				cfg.WebSub.VerifyTimeoutMin = iv
+			case "poll_interval_minutes":
+				cfg.WebSub.PollIntervalMinutes = iv
+			case "push_max_age_hours":
+				cfg.WebSub.PushMaxAgeHours = iv
+			}
+		}
+	case "storage":
+		switch key {
+		case "backend":
+			cfg.Storage.Backend = strings.ToLower(value)
+		case "csv_dir":
+			cfg.Storage.CSVDir = value
+		case "sqlite_path":
+			cfg.Storage.SQLitePath = value
+		}
+	case "category_max_age":
+		cfg.CategoryMaxAge[strings.ToLower(key)] = value
+	case "category_min_duration", "category_max_duration":
+		d, err := parseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", section, key, err)
+		}
+		if section == "category_min_duration" {
+			cfg.CategoryMinDuration[strings.ToLower(key)] = d
+		} else {
+			cfg.CategoryMaxDuration[strings.ToLower(key)] = d
+		}
+	case "category_include":
+		cfg.CategoryInclude[strings.ToLower(key)] = value
+	case "category_exclude":
+		cfg.CategoryExclude[strings.ToLower(key)] = value
+	case "category_routes":
+		cfg.CategoryRoutes[strings.ToLower(key)] = value
+	case "language_routing":
+		switch key {
+		case "categories":
+			cfg.LanguageRouting.Categories = nil
+			for _, category := range strings.Split(value, ",") {
+				if category = strings.ToLower(strings.TrimSpace(category)); category != "" {
+					cfg.LanguageRouting.Categories = append(cfg.LanguageRouting.Categories, category)
+				}
+			}
+		case "fallback":
+			cfg.LanguageRouting.Fallback = strings.ToLower(value)
+		}
+	case "category_filter", "channel_filter":
+		prog, err := expr.Compile(value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", section, key, err)
+		}
+		if section == "category_filter" {
+			cfg.CategoryFilter[strings.ToLower(key)] = prog
+		} else {
+			cfg.ChannelFilter[key] = prog
 		}
 	case "filters":
+		switch key {
+		case "max_age":
+			cfg.Filters.MaxAge = value
+			return nil
+		case "min_duration", "max_duration":
+			d, err := parseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if key == "min_duration" {
+				cfg.Filters.MinDuration = d
+			} else {
+				cfg.Filters.MaxDuration = d
+			}
+			return nil
+		case "unknown_duration":
+			cfg.Filters.UnknownDuration = strings.ToLower(value)
+			return nil
+		}
 		bv, err := strconv.ParseBool(strings.ToLower(value))
 		if err != nil {
 			return fmt.Errorf("invalid bool for %s: %w", key, err)
@@ -151,6 +432,18 @@ func applySection(cfg *AppConfig, section, key, value string) error {
 	return nil
 }
 
+// parseDuration accepts Go durations such as 90s or 4h; a bare number is seconds.
+func parseDuration(value string) (time.Duration, error) {
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q: want e.g. 90s, 10m or 4h", value)
+	}
+	return d, nil
+}
+
 func trimQuotes(v string) string {
 	v = strings.TrimSpace(v)
 	if len(v) >= 2 {
@@ -2,25 +2,40 @@ package controller
 
 import (
 	"log"
-	"time"
+	"sort"
+	"sync"
 
+	"github.com/hellomyzn/yt-notifier/internal/model"
 	"github.com/hellomyzn/yt-notifier/internal/repository"
 	"github.com/hellomyzn/yt-notifier/internal/service"
 )
 
 type JobController interface {
 	RunOnce() error
+	// Poll fetches and notifies only the given channels, e.g. those without a working WebSub subscription.
+	Poll(channels []model.ChannelDTO) error
 }
 
 type jobController struct {
-	chRepo     repository.ChannelRepository
-	feedSvc    service.FeedService
-	notifySvc  service.NotifyService
-	fetchSleep time.Duration
+	chRepo    repository.ChannelRepository
+	feedSvc   service.FeedService
+	notifySvc service.NotifyService
+	workers   int
 }
 
-func NewJobController(chRepo repository.ChannelRepository, fs service.FeedService, ns service.NotifyService, fetchSleep time.Duration) JobController {
-	return &jobController{chRepo: chRepo, feedSvc: fs, notifySvc: ns, fetchSleep: fetchSleep}
+// NewJobController builds the job. workers bounds how many channels are fetched concurrently;
+// pacing between requests is left to the rate-limited HTTP clients of the repositories.
+func NewJobController(chRepo repository.ChannelRepository, fs service.FeedService, ns service.NotifyService, workers int) JobController {
+	if workers < 1 {
+		workers = 1
+	}
+	return &jobController{chRepo: chRepo, feedSvc: fs, notifySvc: ns, workers: workers}
+}
+
+type fetchResult struct {
+	videos []model.VideoDTO
+	err    error
+	done   chan struct{}
 }
 
 func (c *jobController) RunOnce() error {
@@ -28,23 +43,67 @@ func (c *jobController) RunOnce() error {
 	if err != nil {
 		return err
 	}
-	for _, ch := range channels {
-		videos, err := c.feedSvc.ListNewVideos(ch)
-		if err != nil {
+	if err := c.Poll(channels); err != nil {
+		return err
+	}
+
+	feedStats := c.feedSvc.Stats()
+	notifyStats := c.notifySvc.Stats()
+	log.Printf("feed stats: rss=%d api=%d rss_fallbacks=%d api_fallbacks=%d saturation_triggers=%d filtered=%d not_modified=%d retries=%d retry_exhausted=%d quota_skips=%d seeded=%d too_old=%d rule_excluded=%d expr_filtered=%d duration_filtered=%d duration_held=%d expr_held=%d routed=%d language_routed=%d", feedStats.RSSFetches, feedStats.APIFetches, feedStats.RSSFallbacks, feedStats.APIFallbacks, feedStats.SaturationTriggers, feedStats.Filtered, feedStats.NotModified, feedStats.Retries, feedStats.RetryExhausted, feedStats.QuotaSkips, feedStats.Seeded, feedStats.TooOld, feedStats.RuleExcluded, feedStats.ExprFiltered, feedStats.DurationFiltered, feedStats.DurationHeld, feedStats.ExprHeld, feedStats.Routed, feedStats.LanguageRouted)
+	log.Printf("notification stats: sent=%d retried_messages=%d retry_attempts=%d failed=%d reconciled=%d redelivered=%d dead_lettered=%d dead_letter_sent=%d", notifyStats.Sent, notifyStats.RetriedMessages, notifyStats.RetryAttempts, notifyStats.Failed, notifyStats.Reconciled, notifyStats.Redelivered, notifyStats.DeadLettered, notifyStats.DeadLetterSent)
+	return nil
+}
+
+func (c *jobController) Poll(channels []model.ChannelDTO) error {
+	// Settle notifications an interrupted run left in the outbox before looking for new ones.
+	if err := c.notifySvc.Reconcile(); err != nil {
+		return err
+	}
+
+	results := make([]fetchResult, len(channels))
+	for i := range results {
+		results[i].done = make(chan struct{})
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < c.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].videos, results[i].err = c.feedSvc.ListNewVideos(channels[i])
+				close(results[i].done)
+			}
+		}()
+	}
+	go func() {
+		for i := range channels {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	// Notify in channel order as soon as each channel's fetch is done, oldest video first,
+	// so the delivery order does not depend on which worker finished first.
+	for i, ch := range channels {
+		<-results[i].done
+		if err := results[i].err; err != nil {
 			log.Printf("failed to list new videos for channel=%s: %v", ch.ChannelID, err)
-			time.Sleep(c.fetchSleep)
 			continue
 		}
+		videos := results[i].videos
+		sort.SliceStable(videos, func(a, b int) bool {
+			return videos[a].PublishedAt.Before(videos[b].PublishedAt)
+		})
 		for _, v := range videos {
-			if err := c.notifySvc.Notify(ch.Category, v); err != nil {
+			if err := c.notifySvc.Notify(v.Category, v); err != nil {
 				log.Printf("failed to notify channel=%s video=%s: %v", ch.ChannelID, v.VideoID, err)
 			}
 		}
-		time.Sleep(c.fetchSleep)
 	}
-	feedStats := c.feedSvc.Stats()
-	notifyStats := c.notifySvc.Stats()
-	log.Printf("feed stats: rss=%d api=%d rss_fallbacks=%d api_fallbacks=%d saturation_triggers=%d", feedStats.RSSFetches, feedStats.APIFetches, feedStats.RSSFallbacks, feedStats.APIFallbacks, feedStats.SaturationTriggers)
-	log.Printf("notification stats: sent=%d retried_messages=%d retry_attempts=%d failed=%d", notifyStats.Sent, notifyStats.RetriedMessages, notifyStats.RetryAttempts, notifyStats.Failed)
+	wg.Wait()
+	if err := c.feedSvc.Commit(); err != nil {
+		log.Printf("failed to save feed state: %v", err)
+	}
 	return nil
 }
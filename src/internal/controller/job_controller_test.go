@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/service"
+)
+
+// delayedFeedService returns each channel's videos newest first after a random delay, so that
+// workers finish in an arbitrary order.
+type delayedFeedService struct {
+	service.FeedService
+	videos map[string][]model.VideoDTO
+
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (f *delayedFeedService) ListNewVideos(ch model.ChannelDTO) ([]model.VideoDTO, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.maxSeen = max(f.maxSeen, f.inFlight)
+	f.mu.Unlock()
+	time.Sleep(time.Duration(1+rand.Intn(20)) * time.Millisecond)
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
+	return append([]model.VideoDTO(nil), f.videos[ch.ChannelID]...), nil
+}
+
+func (f *delayedFeedService) Commit() error { return nil }
+
+type recordingNotifyService struct {
+	service.NotifyService
+	mu       sync.Mutex
+	notified []string
+}
+
+func (n *recordingNotifyService) Reconcile() error { return nil }
+
+func (n *recordingNotifyService) Notify(_ string, v model.VideoDTO) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notified = append(n.notified, v.VideoID)
+	return nil
+}
+
+func TestPollNotifiesInChannelOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feed := &delayedFeedService{videos: map[string][]model.VideoDTO{}}
+	var (
+		channels []model.ChannelDTO
+		want     []string
+	)
+	for c := 0; c < 8; c++ {
+		id := fmt.Sprintf("UC%d", c)
+		channels = append(channels, model.ChannelDTO{ChannelID: id})
+		for v := 2; v >= 0; v-- {
+			feed.videos[id] = append(feed.videos[id], model.VideoDTO{VideoID: fmt.Sprintf("%s-%d", id, v), ChannelID: id, PublishedAt: base.Add(time.Duration(v) * time.Hour)})
+		}
+		for v := 0; v < 3; v++ {
+			want = append(want, fmt.Sprintf("%s-%d", id, v))
+		}
+	}
+	notify := &recordingNotifyService{}
+
+	if err := NewJobController(nil, feed, notify, 4).Poll(channels); err != nil {
+		t.Fatalf("Poll error: %v", err)
+	}
+	if !reflect.DeepEqual(notify.notified, want) {
+		t.Fatalf("notified %v, want %v", notify.notified, want)
+	}
+	if feed.maxSeen < 2 {
+		t.Fatalf("expected concurrent fetches with 4 workers, saw at most %d", feed.maxSeen)
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+	"github.com/hellomyzn/yt-notifier/internal/service"
+)
+
+// maxPushBody bounds a pushed feed; YouTube pushes one entry at a time.
+const maxPushBody = 1 << 20
+
+// WebSubController serves the WebSub callback and runs the subscription loop of the serve mode.
+type WebSubController struct {
+	chRepo       repository.ChannelRepository
+	job          JobController
+	websub       service.WebSubService
+	syncInterval time.Duration
+	pollInterval time.Duration
+
+	// mu serialises pushes and fallback polls so one video is never notified twice concurrently.
+	mu sync.Mutex
+}
+
+func NewWebSubController(chRepo repository.ChannelRepository, job JobController, ws service.WebSubService, syncInterval, pollInterval time.Duration) *WebSubController {
+	return &WebSubController{chRepo: chRepo, job: job, websub: ws, syncInterval: syncInterval, pollInterval: pollInterval}
+}
+
+func (c *WebSubController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		lease, _ := strconv.Atoi(q.Get("hub.lease_seconds"))
+		challenge, ok := c.websub.Verify(q.Get("hub.mode"), q.Get("hub.topic"), q.Get("hub.challenge"), time.Duration(lease)*time.Second)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, challenge)
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPushBody))
+		if err != nil {
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		err = c.websub.HandlePush(r.URL.Query().Get("channel_id"), body, r.Header.Get("X-Hub-Signature"))
+		c.mu.Unlock()
+		if errors.Is(err, service.ErrInvalidSignature) {
+			// The spec asks subscribers to acknowledge and ignore content with a bad signature.
+			log.Printf("ignoring websub push with an invalid signature from %s", r.RemoteAddr)
+		} else if err != nil {
+			log.Printf("failed to handle websub push: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Run catches up with one full poll, then keeps the subscriptions in sync and polls the
+// channels without an active subscription until ctx is cancelled.
+func (c *WebSubController) Run(ctx context.Context) error {
+	c.mu.Lock()
+	err := c.job.RunOnce()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	c.sync()
+
+	syncTicker := time.NewTicker(c.syncInterval)
+	defer syncTicker.Stop()
+	pollTicker := time.NewTicker(c.pollInterval)
+	defer pollTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-syncTicker.C:
+			c.sync()
+		case <-pollTicker.C:
+			targets := c.websub.PollTargets()
+			if len(targets) == 0 {
+				continue
+			}
+			log.Printf("polling %d channels without an active websub subscription", len(targets))
+			c.mu.Lock()
+			err := c.job.Poll(targets)
+			c.mu.Unlock()
+			if err != nil {
+				log.Printf("fallback poll failed: %v", err)
+			}
+		}
+	}
+}
+
+func (c *WebSubController) sync() {
+	channels, err := c.chRepo.ListEnabled()
+	if err != nil {
+		log.Printf("failed to list channels for websub: %v", err)
+		return
+	}
+	c.websub.Sync(channels)
+}
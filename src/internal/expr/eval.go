@@ -0,0 +1,148 @@
+package expr
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type literalNode struct {
+	t valueType
+	v any
+}
+
+func (n *literalNode) typ() valueType { return n.t }
+func (n *literalNode) eval(*env) any  { return n.v }
+
+type fieldNode struct{ f field }
+
+func (n *fieldNode) typ() valueType  { return n.f.typ }
+func (n *fieldNode) eval(e *env) any { return n.f.value(e) }
+
+type notNode struct{ x node }
+
+func (n *notNode) typ() valueType { return typeBool }
+func (n *notNode) eval(e *env) any {
+	if x, ok := n.x.eval(e).(bool); ok {
+		return !x
+	}
+	return unknown{}
+}
+
+type logicalNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicalNode) typ() valueType { return typeBool }
+
+// eval uses three-valued logic: a known operand that decides the result (true for ||, false
+// for &&) wins over an unknown one.
+func (n *logicalNode) eval(e *env) any {
+	l := n.left.eval(e)
+	if l == n.or {
+		return l
+	}
+	r := n.right.eval(e)
+	if r == n.or {
+		return r
+	}
+	if _, ok := l.(unknown); ok {
+		return l
+	}
+	return r
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) typ() valueType { return typeBool }
+
+func (n *compareNode) eval(e *env) any {
+	l, r := n.left.eval(e), n.right.eval(e)
+	if isUnknown(l) || isUnknown(r) {
+		return unknown{}
+	}
+	var c int
+	switch l := l.(type) {
+	case float64:
+		c = compare(l, r.(float64))
+	case time.Duration:
+		c = compare(l, r.(time.Duration))
+	case string:
+		c = strings.Compare(l, r.(string))
+	case bool:
+		if l != r.(bool) {
+			c = 1
+		}
+	}
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func compare[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// callNode is a string or list method. contains, startsWith and endsWith ignore case; matches
+// uses the regex as written.
+type callNode struct {
+	recv   node
+	method string
+	arg    string
+	lower  string
+	re     *regexp.Regexp
+}
+
+func (n *callNode) typ() valueType { return typeBool }
+
+func (n *callNode) eval(e *env) any {
+	recv := n.recv.eval(e)
+	if isUnknown(recv) {
+		return unknown{}
+	}
+	if tags, ok := recv.([]string); ok {
+		for _, tag := range tags {
+			if strings.EqualFold(tag, n.arg) {
+				return true
+			}
+		}
+		return false
+	}
+	s := recv.(string)
+	switch n.method {
+	case "matches":
+		return n.re.MatchString(s)
+	case "startsWith":
+		return strings.HasPrefix(strings.ToLower(s), n.lower)
+	case "endsWith":
+		return strings.HasSuffix(strings.ToLower(s), n.lower)
+	default:
+		return strings.Contains(strings.ToLower(s), n.lower)
+	}
+}
+
+func isUnknown(v any) bool {
+	_, ok := v.(unknown)
+	return ok
+}
@@ -0,0 +1,155 @@
+// Package expr implements the filter expressions of app.yaml, e.g.
+//
+//	duration > 5m && !title.contains("#shorts") && views_per_hour > 100
+//
+// An expression is compiled and type-checked once and then evaluated against each video.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+// Program is a compiled boolean expression.
+type Program struct {
+	src  string
+	root node
+}
+
+// Error is a syntax or type error; Pos is the 1-based character position in the expression.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// Result is the outcome of an expression for one video.
+type Result int
+
+const (
+	False Result = iota
+	True
+	// Unknown means the outcome depends on enrichment fields the video does not have.
+	Unknown
+)
+
+func (r Result) String() string {
+	return [...]string{"false", "true", "unknown"}[r]
+}
+
+// unknown is the value of an enrichment field of a video that was not enriched, and of
+// anything computed from it. Logical operators follow three-valued logic, so that
+// `duration > 5m && !title.contains("#shorts")` is still False for a "#shorts" title.
+type unknown struct{}
+
+// Compile parses and type-checks src, which must evaluate to a bool.
+func Compile(src string) (*Program, error) {
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf(p.tok.pos, "unexpected %s", p.tok)
+	}
+	if root.typ() != typeBool {
+		return nil, p.errorf(0, "expression is a %s, not a bool", root.typ())
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// Eval reports whether v matches; now is used for age and views_per_hour.
+func (p *Program) Eval(v model.VideoDTO, now time.Time) Result {
+	switch x := p.root.eval(&env{video: v, now: now}).(type) {
+	case bool:
+		if x {
+			return True
+		}
+		return False
+	default:
+		return Unknown
+	}
+}
+
+func (p *Program) String() string {
+	return p.src
+}
+
+type env struct {
+	video model.VideoDTO
+	now   time.Time
+}
+
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeDuration
+	typeString
+	typeList
+)
+
+func (t valueType) String() string {
+	return [...]string{"bool", "number", "duration", "string", "list"}[t]
+}
+
+type field struct {
+	typ        valueType
+	get        func(e *env) any
+	enrichment bool // unknown unless the video was enriched
+}
+
+func (f field) value(e *env) any {
+	if f.enrichment && !e.video.Enriched {
+		return unknown{}
+	}
+	return f.get(e)
+}
+
+// fields are the video attributes an expression can use. Enrichment fields are unknown when
+// the video was not looked up via videos.list.
+var fields = map[string]field{
+	"title":       {typ: typeString, get: func(e *env) any { return e.video.Title }},
+	"description": {typ: typeString, get: func(e *env) any { return e.video.Description }},
+	"channel":     {typ: typeString, get: func(e *env) any { return e.video.ChannelName }},
+	"channel_id":  {typ: typeString, get: func(e *env) any { return e.video.ChannelID }},
+	"video_id":    {typ: typeString, get: func(e *env) any { return e.video.VideoID }},
+	"kind":        {typ: typeString, get: func(e *env) any { return string(e.video.Kind) }},
+	"language":    {typ: typeString, get: func(e *env) any { return e.video.DefaultAudioLanguage }, enrichment: true},
+	"tags":        {typ: typeList, get: func(e *env) any { return e.video.Tags }, enrichment: true},
+	"enriched":    {typ: typeBool, get: func(e *env) any { return e.video.Enriched }},
+	"duration":    {typ: typeDuration, get: func(e *env) any { return e.video.Duration }, enrichment: true},
+	"age":         {typ: typeDuration, get: func(e *env) any { return e.now.Sub(e.video.PublishedAt) }},
+	"views":       {typ: typeNumber, get: func(e *env) any { return float64(e.video.ViewCount) }, enrichment: true},
+	"likes":       {typ: typeNumber, get: func(e *env) any { return float64(e.video.LikeCount) }, enrichment: true},
+	"views_per_hour": {typ: typeNumber, enrichment: true, get: func(e *env) any {
+		// Within the first hour the raw count is used so that a few early views do not spike.
+		hours := max(e.now.Sub(e.video.PublishedAt).Hours(), 1)
+		return float64(e.video.ViewCount) / hours
+	}},
+}
+
+func (p *parser) errorf(offset int, format string, args ...any) *Error {
+	return &Error{Pos: utf8.RuneCountInString(p.src[:offset]) + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
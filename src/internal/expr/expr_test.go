@@ -0,0 +1,98 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+func TestEval(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	video := model.VideoDTO{
+		Title:       "Tokyo walk #Shorts",
+		PublishedAt: now.Add(-4 * time.Hour),
+		Enriched:    true,
+		Duration:    7 * time.Minute,
+		ViewCount:   1000,
+		Tags:        []string{"Travel", "Japan"},
+		Kind:        model.VideoKindRegular,
+	}
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`duration > 5m && !title.contains("#shorts") && views_per_hour > 100`, false},
+		{`duration > 5m && views_per_hour > 100`, true},
+		{`duration >= 1h || age < 2d`, true},
+		{`tags.contains("japan") && kind == "regular"`, true},
+		{`title.matches('^Tokyo\b') && title.startsWith("tokyo")`, true},
+		{`!(enriched == true) || views < 500`, false},
+		{`language == "" && likes == 0`, true},
+	}
+	for _, c := range cases {
+		p, err := Compile(c.src)
+		if err != nil {
+			t.Fatalf("Compile(%s): %v", c.src, err)
+		}
+		want := False
+		if c.want {
+			want = True
+		}
+		if got := p.Eval(video, now); got != want {
+			t.Errorf("%s = %v, want %v", c.src, got, want)
+		}
+	}
+}
+
+func TestEvalUnenriched(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	video := model.VideoDTO{Title: "Tokyo walk #Shorts", PublishedAt: now.Add(-4 * time.Hour)}
+	cases := []struct {
+		src  string
+		want Result
+	}{
+		{`duration > 5m && !title.contains('#shorts')`, False},
+		{`duration > 5m && title.contains('#shorts')`, Unknown},
+		{`duration > 5m || title.contains('#shorts')`, True},
+		{`!(duration > 5m)`, Unknown},
+		{`!enriched || views > 100`, True},
+		{`enriched && views > 100`, False},
+		{`tags.contains("japan") || language == "ja"`, Unknown},
+	}
+	for _, c := range cases {
+		p, err := Compile(c.src)
+		if err != nil {
+			t.Fatalf("Compile(%s): %v", c.src, err)
+		}
+		if got := p.Eval(video, now); got != c.want {
+			t.Errorf("%s = %v, want %v", c.src, got, c.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		src string
+		pos int
+	}{
+		{`duration > 5m &&`, 17},
+		{`views > "100"`, 7},
+		{`title.contains("a"`, 19},
+		{`titel == "x"`, 1},
+		{`views + 1`, 7},
+		{`title.matches("(")`, 15},
+		{`duration`, 1},
+	}
+	for _, c := range cases {
+		_, err := Compile(c.src)
+		var exprErr *Error
+		if !errors.As(err, &exprErr) {
+			t.Fatalf("Compile(%s) = %v, want an *Error", c.src, err)
+		}
+		if exprErr.Pos != c.pos {
+			t.Errorf("Compile(%s): %v, want position %d", c.src, err, c.pos)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokDuration
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	pos  int // byte offset in the source
+	text string
+	num  float64
+	dur  time.Duration
+	str  string
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ".", ","}
+
+// next reads the token at p.off into p.tok.
+func (p *parser) next() error {
+	for p.off < len(p.src) && (p.src[p.off] == ' ' || p.src[p.off] == '\t') {
+		p.off++
+	}
+	start := p.off
+	if start == len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return nil
+	}
+	c := p.src[start]
+	switch {
+	case c == '"' || c == '\'':
+		return p.lexString(start, c)
+	case c >= '0' && c <= '9':
+		return p.lexNumber(start)
+	case c == '_' || isLetter(c):
+		end := start
+		for end < len(p.src) && (p.src[end] == '_' || isLetter(p.src[end]) || isDigit(p.src[end])) {
+			end++
+		}
+		p.off = end
+		p.tok = token{kind: tokIdent, pos: start, text: p.src[start:end]}
+		return nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(p.src[start:], op) {
+			p.off += len(op)
+			p.tok = token{kind: tokOp, pos: start, text: op}
+			return nil
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[start:])
+	return p.errorf(start, "unexpected character %q", r)
+}
+
+func (p *parser) lexString(start int, quote byte) error {
+	var b strings.Builder
+	i := start + 1
+	for i < len(p.src) {
+		c := p.src[i]
+		switch {
+		case c == quote:
+			p.off = i + 1
+			p.tok = token{kind: tokString, pos: start, text: p.src[start:p.off], str: b.String()}
+			return nil
+		case c == '\\' && i+1 < len(p.src):
+			// Only quotes and backslashes are escaped so that regexes can be written as-is.
+			if n := p.src[i+1]; n == quote || n == '\\' {
+				b.WriteByte(n)
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return p.errorf(start, "unterminated string")
+}
+
+// lexNumber reads a number or a duration such as 90s, 1h30m or 2d.
+func (p *parser) lexNumber(start int) error {
+	end := start
+	for end < len(p.src) && (isDigit(p.src[end]) || p.src[end] == '.' || isLetter(p.src[end])) {
+		end++
+	}
+	p.off = end
+	text := p.src[start:end]
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		p.tok = token{kind: tokNumber, pos: start, text: text, num: n}
+		return nil
+	}
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			p.tok = token{kind: tokDuration, pos: start, text: text, dur: time.Duration(n) * 24 * time.Hour}
+			return nil
+		}
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return p.errorf(start, "invalid number or duration %q", text)
+	}
+	p.tok = token{kind: tokDuration, pos: start, text: text, dur: d}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsLetter(rune(c))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,223 @@
+package expr
+
+import (
+	"regexp"
+	"strings"
+)
+
+type parser struct {
+	src string
+	off int
+	tok token
+}
+
+type node interface {
+	typ() valueType
+	eval(e *env) any
+}
+
+// parseOr parses `and ("||" and)*`.
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		pos := p.tok.pos
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.wantBools(pos, "||", left, right); err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses `unary ("&&" unary)*`.
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		pos := p.tok.pos
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.wantBools(pos, "&&", left, right); err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses `"!" unary | comparison`.
+func (p *parser) parseUnary() (node, error) {
+	if !p.isOp("!") {
+		return p.parseComparison()
+	}
+	pos := p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if x.typ() != typeBool {
+		return nil, p.errorf(pos, "! needs a bool, got %s", x.typ())
+	}
+	return &notNode{x: x}, nil
+}
+
+// parseComparison parses `postfix (op postfix)?`.
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokOp {
+		return left, nil
+	}
+	op := p.tok.text
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	pos := p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ() != right.typ() {
+		return nil, p.errorf(pos, "cannot compare %s %s %s", left.typ(), op, right.typ())
+	}
+	ordered := left.typ() == typeNumber || left.typ() == typeDuration || left.typ() == typeString
+	if left.typ() == typeList || (!ordered && op != "==" && op != "!=") {
+		return nil, p.errorf(pos, "operator %s is not defined on %s", op, left.typ())
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+// parsePostfix parses `primary ("." method "(" args ")")*`.
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(".") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokIdent {
+			return nil, p.errorf(p.tok.pos, "expected a method name, got %s", p.tok)
+		}
+		method, pos := p.tok.text, p.tok.pos
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokString {
+			return nil, p.errorf(p.tok.pos, "%s expects a string literal, got %s", method, p.tok)
+		}
+		arg, argPos := p.tok.str, p.tok.pos
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if x, err = p.method(x, method, pos, arg, argPos); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) method(recv node, name string, pos int, arg string, argPos int) (node, error) {
+	call := &callNode{recv: recv, method: name, arg: arg, lower: strings.ToLower(arg)}
+	switch {
+	case recv.typ() == typeList && name == "contains":
+	case recv.typ() != typeString:
+		return nil, p.errorf(pos, "%s has no method %s", recv.typ(), name)
+	case name == "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, p.errorf(argPos, "invalid regex: %v", err)
+		}
+		call.re = re
+	case name == "contains", name == "startsWith", name == "endsWith":
+	default:
+		return nil, p.errorf(pos, "unknown method %s (contains, startsWith, endsWith, matches)", name)
+	}
+	return call, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{t: typeNumber, v: tok.num}, p.next()
+	case tokDuration:
+		return &literalNode{t: typeDuration, v: tok.dur}, p.next()
+	case tokString:
+		return &literalNode{t: typeString, v: tok.str}, p.next()
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{t: typeBool, v: tok.text == "true"}, p.next()
+		}
+		f, ok := fields[tok.text]
+		if !ok {
+			return nil, p.errorf(tok.pos, "unknown field %s (fields: %s)", tok.text, fieldNames())
+		}
+		return &fieldNode{f: f}, p.next()
+	case tokOp:
+		if tok.text == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+	}
+	return nil, p.errorf(tok.pos, "unexpected %s", tok)
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf(p.tok.pos, "expected %q, got %s", op, p.tok)
+	}
+	return p.next()
+}
+
+func (p *parser) wantBools(pos int, op string, left, right node) error {
+	if left.typ() != typeBool || right.typ() != typeBool {
+		return p.errorf(pos, "%s needs bools, got %s and %s", op, left.typ(), right.typ())
+	}
+	return nil
+}
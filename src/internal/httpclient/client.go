@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultUserAgent = "yt-notifier/1.0 (+https://github.com/hellomyzn/yt-notifier)"
+
+// Config is the transport-level configuration shared by every outgoing client.
+type Config struct {
+	ProxyURL            string // empty uses HTTP(S)_PROXY from the environment
+	UserAgent           string
+	MaxIdleConnsPerHost int
+}
+
+// Pool owns one connection-pooling transport; Client hands out clients with their own
+// timeout and optional rate limiter on top of it.
+type Pool struct {
+	transport *http.Transport
+	userAgent string
+}
+
+func NewPool(cfg Config) (*Pool, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", cfg.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < cfg.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = cfg.MaxIdleConnsPerHost
+		}
+	}
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	return &Pool{transport: transport, userAgent: userAgent}, nil
+}
+
+// Client returns a client bounded by timeout (zero means none) whose requests wait on limiter (may be nil).
+// With a limiter the timeout starts once the request's slot comes up.
+func (p *Pool) Client(timeout time.Duration, limiter *HostRateLimiter) *http.Client {
+	var rt http.RoundTripper = &userAgentTransport{base: p.transport, userAgent: p.userAgent}
+	if limiter != nil {
+		return &http.Client{Transport: &Transport{Base: rt, Limiter: limiter, Timeout: timeout}}
+	}
+	return &http.Client{Transport: rt, Timeout: timeout}
+}
+
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Rebase moves raw onto base: scheme and host come from base, and base's path is prefixed to
+// raw's path. It is used to point webhook URLs at a local stand-in.
+func Rebase(raw, base string) (string, error) {
+	if base == "" {
+		return raw, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base url %q: %w", base, err)
+	}
+	u.Scheme = b.Scheme
+	u.Host = b.Host
+	u.Path = strings.TrimSuffix(b.Path, "/") + u.Path
+	u.RawPath = ""
+	return u.String(), nil
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HostRateLimiter spaces out requests to the same host by at least Interval,
+// no matter how many goroutines share it.
+type HostRateLimiter struct {
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func NewHostRateLimiter(interval time.Duration) *HostRateLimiter {
+	return &HostRateLimiter{Interval: interval, next: map[string]time.Time{}}
+}
+
+// Wait blocks until a request to host may be sent.
+func (l *HostRateLimiter) Wait(host string) {
+	if l == nil || l.Interval <= 0 {
+		return
+	}
+	l.mu.Lock()
+	if l.next == nil {
+		l.next = map[string]time.Time{}
+	}
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.Interval)
+	l.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Transport applies a HostRateLimiter to every request before handing it to Base. Timeout
+// (zero means none) bounds the request from the moment it leaves the queue until its body is
+// closed, so time spent waiting for a slot does not count against it the way http.Client.Timeout would.
+type Transport struct {
+	Base    http.RoundTripper
+	Limiter *HostRateLimiter
+	Timeout time.Duration
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.Limiter.Wait(req.URL.Host)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Timeout <= 0 {
+		return base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the per-request timeout once the caller is done with the body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
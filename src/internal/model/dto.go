@@ -8,13 +8,93 @@ type ChannelDTO struct {
 	Name       string
 	Enabled    bool
 	FetchLimit int
+	// IncludeRules and ExcludeRules are optional per-channel rule lists, added to the category's.
+	IncludeRules string
+	ExcludeRules string
+	// Routes sends matching videos to other categories; checked before the category's routes.
+	Routes string
 }
 
+// VideoKind classifies an upload so that filters can tell Shorts and live content apart.
+type VideoKind string
+
+const (
+	VideoKindUnknown     VideoKind = ""
+	VideoKindRegular     VideoKind = "regular"
+	VideoKindShort       VideoKind = "short"
+	VideoKindLive        VideoKind = "live"         // live now, or a scheduled live stream
+	VideoKindPremiere    VideoKind = "premiere"     // upcoming premiere
+	VideoKindLiveArchive VideoKind = "live_archive" // finished live stream
+)
+
 type VideoDTO struct {
 	VideoID     string
 	Title       string
+	Description string
 	Link        string
 	ChannelID   string
 	ChannelName string
 	PublishedAt time.Time
+	Kind        VideoKind
+	Category    string // set by the feed service: the channel's category or the one a route chose
+
+	// The fields below are only filled in when the video was enriched via videos.list.
+	Enriched             bool
+	Duration             time.Duration
+	ViewCount            int64
+	LikeCount            int64
+	Tags                 []string
+	DefaultAudioLanguage string
+	DefaultLanguage      string // language of the title and description
+	LiveBroadcastContent string // none, live or upcoming
+	ScheduledStartTime   time.Time
+}
+
+// NotifiedRecord is one row of the notification history.
+type NotifiedRecord struct {
+	VideoID     string
+	ChannelID   string
+	PublishedAt time.Time
+	NotifiedAt  time.Time
+	Category    string // the category the video was routed to; empty in older history
+}
+
+// OutboxState tracks a notification through the outbox.
+type OutboxState string
+
+const (
+	OutboxPending OutboxState = "pending" // written before dispatch
+	OutboxSent    OutboxState = "sent"    // delivered, history not yet recorded
+	OutboxFailed  OutboxState = "failed"  // dispatch gave up; the entry is in the dead-letter queue
+)
+
+// OutboxEntry is one notification of Video to the destination configured for Category.
+type OutboxEntry struct {
+	Category  string
+	Video     VideoDTO
+	State     OutboxState
+	Attempts  int
+	LastError string
+	UpdatedAt time.Time
+
+	// Dead-letter bookkeeping for failed entries. A zero NextRetryAt means automatic redelivery gave up.
+	Failures    int
+	NextRetryAt time.Time
+}
+
+// FeedValidators are the HTTP cache validators of a channel feed, sent back as If-None-Match / If-Modified-Since.
+type FeedValidators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v FeedValidators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// DeletedVideo is a video the WebSub hub reported as removed.
+type DeletedVideo struct {
+	VideoID   string
+	ChannelID string
+	DeletedAt time.Time
 }
@@ -12,10 +12,30 @@ import (
 )
 
 type NotificationContent struct {
-	Title    string
-	Message  string
-	URL      string
-	ThumbURL string
+	Title       string
+	Message     string
+	URL         string
+	ThumbURL    string
+	ChannelName string
+	PublishedAt time.Time
+}
+
+const (
+	OutputDiscord = "discord"
+	OutputSlack   = "slack"
+)
+
+// New returns the notifier for an output name used in app.yaml (discord or slack).
+// A nil client falls back to http.DefaultClient.
+func New(output, webhook string, client *http.Client) (Notifier, error) {
+	switch strings.ToLower(strings.TrimSpace(output)) {
+	case OutputDiscord:
+		return &DiscordNotifier{Webhook: webhook, Client: client}, nil
+	case OutputSlack:
+		return &SlackNotifier{Webhook: webhook, Client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown output %q", output)
+	}
 }
 
 type Notifier interface {
@@ -0,0 +1,106 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SlackNotifier posts Block Kit messages to a Slack incoming webhook.
+type SlackNotifier struct {
+	Webhook string
+	Client  *http.Client
+}
+
+func (n *SlackNotifier) Send(c NotificationContent) error {
+	b, _ := json.Marshal(slackPayload(c))
+	cli := n.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Post(n.Webhook, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &SlackHTTPError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfterHeader(resp.Header.Get("Retry-After")),
+			Message:    strings.TrimSpace(string(snippet)),
+		}
+	}
+	return nil
+}
+
+func slackPayload(c NotificationContent) map[string]any {
+	title := fmt.Sprintf("*<%s|%s>*", c.URL, slackEscape(c.Title))
+	if c.URL == "" {
+		title = fmt.Sprintf("*%s*", slackEscape(c.Title))
+	}
+	section := map[string]any{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": title},
+	}
+	if c.ThumbURL != "" {
+		section["accessory"] = map[string]string{
+			"type":      "image",
+			"image_url": c.ThumbURL,
+			"alt_text":  c.Title,
+		}
+	}
+
+	var context []map[string]string
+	if c.ChannelName != "" {
+		context = append(context, map[string]string{"type": "mrkdwn", "text": slackEscape(c.ChannelName)})
+	}
+	if !c.PublishedAt.IsZero() {
+		// Slack renders the date in each reader's own timezone; the RFC3339 text is the fallback.
+		context = append(context, map[string]string{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", c.PublishedAt.Unix(), c.PublishedAt.Format(time.RFC3339)),
+		})
+	}
+	if len(context) == 0 && c.Message != "" {
+		context = append(context, map[string]string{"type": "mrkdwn", "text": slackEscape(c.Message)})
+	}
+
+	blocks := []map[string]any{section}
+	if len(context) > 0 {
+		blocks = append(blocks, map[string]any{"type": "context", "elements": context})
+	}
+	return map[string]any{
+		"text":   fmt.Sprintf("%s %s", c.Title, c.URL), // fallback for notifications
+		"blocks": blocks,
+	}
+}
+
+// slackEscape escapes the control characters of Slack's mrkdwn format.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+type SlackHTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *SlackHTTPError) Error() string {
+	if e == nil {
+		return ""
+	}
+	msg := fmt.Sprintf("slack webhook status %d", e.StatusCode)
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	if e.RetryAfter > 0 {
+		msg = fmt.Sprintf("%s (retry after %s)", msg, e.RetryAfter)
+	}
+	return msg
+}
@@ -0,0 +1,100 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type slackBlock struct {
+	Type string `json:"type"`
+	Text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"text"`
+	Accessory struct {
+		Type     string `json:"type"`
+		ImageURL string `json:"image_url"`
+		AltText  string `json:"alt_text"`
+	} `json:"accessory"`
+	Elements []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"elements"`
+}
+
+func TestSlackNotifierPayload(t *testing.T) {
+	var got struct {
+		Text   string       `json:"text"`
+		Blocks []slackBlock `json:"blocks"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	published := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	n := &SlackNotifier{Webhook: srv.URL}
+	err := n.Send(NotificationContent{
+		Title:       "Q&A <live> recap",
+		URL:         "https://www.youtube.com/watch?v=VIDEO1",
+		ThumbURL:    "https://i.ytimg.com/vi/VIDEO1/hqdefault.jpg",
+		ChannelName: "Tips & Tricks",
+		PublishedAt: published,
+	})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	if len(got.Blocks) != 2 {
+		t.Fatalf("expected a section and a context block, got %+v", got.Blocks)
+	}
+	section := got.Blocks[0]
+	if want := "*<https://www.youtube.com/watch?v=VIDEO1|Q&amp;A &lt;live&gt; recap>*"; section.Type != "section" || section.Text.Type != "mrkdwn" || section.Text.Text != want {
+		t.Fatalf("title block = %+v, want mrkdwn %q", section, want)
+	}
+	if section.Accessory.Type != "image" || section.Accessory.ImageURL != "https://i.ytimg.com/vi/VIDEO1/hqdefault.jpg" || section.Accessory.AltText != "Q&A <live> recap" {
+		t.Fatalf("unexpected thumbnail accessory %+v", section.Accessory)
+	}
+	context := got.Blocks[1]
+	if context.Type != "context" || len(context.Elements) != 2 {
+		t.Fatalf("unexpected context block %+v", context)
+	}
+	if context.Elements[0].Text != "Tips &amp; Tricks" {
+		t.Fatalf("channel element = %q", context.Elements[0].Text)
+	}
+	if want := "<!date^1714557600^{date_short_pretty} {time}|2024-05-01T10:00:00Z>"; context.Elements[1].Text != want {
+		t.Fatalf("date element = %q, want %q", context.Elements[1].Text, want)
+	}
+	if !strings.Contains(got.Text, "https://www.youtube.com/watch?v=VIDEO1") {
+		t.Fatalf("fallback text %q should contain the link", got.Text)
+	}
+}
+
+func TestSlackNotifierRateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("rate_limited"))
+	}))
+	defer srv.Close()
+
+	err := (&SlackNotifier{Webhook: srv.URL}).Send(NotificationContent{Title: "t", URL: "https://example.test"})
+	var slackErr *SlackHTTPError
+	if !errors.As(err, &slackErr) {
+		t.Fatalf("expected a *SlackHTTPError, got %v", err)
+	}
+	if slackErr.StatusCode != http.StatusTooManyRequests || slackErr.RetryAfter != 7*time.Second || slackErr.Message != "rate_limited" {
+		t.Fatalf("unexpected error %+v", slackErr)
+	}
+}
@@ -13,11 +13,42 @@ type ChannelRepository interface {
 	ListEnabled() ([]model.ChannelDTO, error)
 }
 
+// ChannelStore is a ChannelRepository that can list disabled channels too and accept new ones, e.g. for migrations.
+type ChannelStore interface {
+	ChannelRepository
+	ListAll() ([]model.ChannelDTO, error)
+	Add(ch model.ChannelDTO) error
+}
+
+var channelHeader = []string{"channel_id", "category", "name", "enabled", "fetch_limit", "include_rules", "exclude_rules", "routes"}
+
 type CSVChannelRepository struct{ Path string }
 
 func (r *CSVChannelRepository) ListEnabled() ([]model.ChannelDTO, error) {
+	all, err := r.read(false)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.ChannelDTO
+	for _, ch := range all {
+		if ch.Enabled {
+			out = append(out, ch)
+		}
+	}
+	return out, nil
+}
+
+// ListAll returns every row including disabled channels; a missing file is an empty store.
+func (r *CSVChannelRepository) ListAll() ([]model.ChannelDTO, error) {
+	return r.read(true)
+}
+
+func (r *CSVChannelRepository) read(allowMissing bool) ([]model.ChannelDTO, error) {
 	f, err := os.Open(r.Path)
 	if err != nil {
+		if allowMissing && os.IsNotExist(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	defer f.Close()
@@ -31,29 +62,62 @@ func (r *CSVChannelRepository) ListEnabled() ([]model.ChannelDTO, error) {
 	}
 	var out []model.ChannelDTO
 	for i, row := range rows {
-		if i == 0 && len(row) > 0 && strings.Contains(strings.ToLower(row[0]), "channel") {
+		if i == 0 && len(row) > 0 && strings.HasPrefix(strings.ToLower(strings.TrimSpace(row[0])), "channel") {
 			continue // header skip
 		}
 		if len(row) < 4 {
 			continue
 		}
-		enabled := strings.EqualFold(strings.TrimSpace(row[3]), "true")
-		if !enabled {
-			continue
-		}
 		var fetchLimit int
 		if len(row) >= 5 {
 			if v, err := strconv.Atoi(strings.TrimSpace(row[4])); err == nil {
 				fetchLimit = v
 			}
 		}
-		out = append(out, model.ChannelDTO{
+		ch := model.ChannelDTO{
 			ChannelID:  strings.TrimSpace(row[0]),
 			Category:   strings.ToLower(strings.TrimSpace(row[1])),
 			Name:       strings.TrimSpace(row[2]),
-			Enabled:    true,
+			Enabled:    strings.EqualFold(strings.TrimSpace(row[3]), "true"),
 			FetchLimit: fetchLimit,
-		})
+		}
+		if len(row) >= 6 {
+			ch.IncludeRules = strings.TrimSpace(row[5])
+		}
+		if len(row) >= 7 {
+			ch.ExcludeRules = strings.TrimSpace(row[6])
+		}
+		if len(row) >= 8 {
+			ch.Routes = strings.TrimSpace(row[7])
+		}
+		out = append(out, ch)
 	}
 	return out, nil
 }
+
+func (r *CSVChannelRepository) Add(ch model.ChannelDTO) error {
+	if err := ensureCSVWithHeader(r.Path, channelHeader); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{
+		ch.ChannelID,
+		ch.Category,
+		ch.Name,
+		strconv.FormatBool(ch.Enabled),
+		strconv.Itoa(ch.FetchLimit),
+		ch.IncludeRules,
+		ch.ExcludeRules,
+		ch.Routes,
+	}); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+// ChannelRefKind tells how a channels.csv entry identifies its channel.
+type ChannelRefKind string
+
+const (
+	ChannelRefID       ChannelRefKind = "id"
+	ChannelRefHandle   ChannelRefKind = "handle"
+	ChannelRefCustom   ChannelRefKind = "custom"
+	ChannelRefUsername ChannelRefKind = "username"
+)
+
+// ChannelRef is a parsed channels.csv entry: a raw UC... ID, an @handle, a /c/ custom URL or a legacy /user/ name.
+type ChannelRef struct {
+	Kind  ChannelRefKind
+	Value string
+}
+
+func (r ChannelRef) String() string {
+	switch r.Kind {
+	case ChannelRefHandle:
+		return "@" + r.Value
+	case ChannelRefCustom:
+		return "c/" + r.Value
+	case ChannelRefUsername:
+		return "user/" + r.Value
+	default:
+		return r.Value
+	}
+}
+
+var ErrChannelNotFound = errors.New("youtube channel not found")
+
+// ChannelIDResolver resolves handles and legacy names to UC... channel IDs.
+type ChannelIDResolver interface {
+	ResolveChannelID(ref ChannelRef) (string, error)
+}
+
+// ParseChannelRef accepts the forms people paste into channels.csv:
+// UCxxxx, @handle, youtube.com/@handle, youtube.com/channel/UCxxxx, youtube.com/c/name and youtube.com/user/name.
+func ParseChannelRef(raw string) (ChannelRef, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return ChannelRef{}, fmt.Errorf("empty channel reference")
+	}
+	if strings.HasPrefix(s, "@") {
+		return ChannelRef{Kind: ChannelRefHandle, Value: s[1:]}, nil
+	}
+	if !strings.Contains(s, "/") {
+		if isChannelID(s) {
+			return ChannelRef{Kind: ChannelRefID, Value: s}, nil
+		}
+		return ChannelRef{}, fmt.Errorf("unrecognized channel reference %q", raw)
+	}
+
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ChannelRef{}, fmt.Errorf("unrecognized channel reference %q: %w", raw, err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 1 && strings.HasPrefix(parts[0], "@") && len(parts[0]) > 1:
+		return ChannelRef{Kind: ChannelRefHandle, Value: parts[0][1:]}, nil
+	case len(parts) >= 2 && parts[0] == "channel" && isChannelID(parts[1]):
+		return ChannelRef{Kind: ChannelRefID, Value: parts[1]}, nil
+	case len(parts) >= 2 && parts[0] == "c" && parts[1] != "":
+		return ChannelRef{Kind: ChannelRefCustom, Value: parts[1]}, nil
+	case len(parts) >= 2 && parts[0] == "user" && parts[1] != "":
+		return ChannelRef{Kind: ChannelRefUsername, Value: parts[1]}, nil
+	}
+	return ChannelRef{}, fmt.Errorf("unrecognized channel reference %q", raw)
+}
+
+func isChannelID(s string) bool {
+	return strings.HasPrefix(s, "UC") && len(s) == 24
+}
+
+// ResolvingChannelRepository resolves non-ID entries of the wrapped repository to channel IDs,
+// remembering every successful lookup in Cache so each entry hits the API only once.
+// Entries that cannot be resolved are reported and left out instead of producing 404 feeds.
+type ResolvingChannelRepository struct {
+	Base     ChannelRepository
+	Resolver ChannelIDResolver // nil when no API key is configured; only cached entries resolve then
+	Cache    *CSVChannelIDCache
+}
+
+func (r *ResolvingChannelRepository) ListEnabled() ([]model.ChannelDTO, error) {
+	channels, err := r.Base.ListEnabled()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.ChannelDTO, 0, len(channels))
+	var unresolved []string
+	for _, ch := range channels {
+		id, err := r.Resolve(ch.ChannelID)
+		if err != nil {
+			log.Printf("cannot resolve channel %q (%s): %v", ch.ChannelID, ch.Name, err)
+			unresolved = append(unresolved, ch.ChannelID)
+			continue
+		}
+		ch.ChannelID = id
+		out = append(out, ch)
+	}
+	if len(unresolved) > 0 {
+		log.Printf("skipping %d unresolved channel(s): %s", len(unresolved), strings.Join(unresolved, ", "))
+	}
+	return out, nil
+}
+
+// Resolve returns the channel ID behind any form ParseChannelRef accepts.
+func (r *ResolvingChannelRepository) Resolve(raw string) (string, error) {
+	ref, err := ParseChannelRef(raw)
+	if err != nil {
+		return "", err
+	}
+	if ref.Kind == ChannelRefID {
+		return ref.Value, nil
+	}
+	if r.Cache != nil {
+		if id, ok, err := r.Cache.Get(ref.String()); err != nil {
+			return "", err
+		} else if ok {
+			return id, nil
+		}
+	}
+	if r.Resolver == nil {
+		return "", fmt.Errorf("resolving %s requires a youtube api key", ref)
+	}
+	id, err := r.Resolver.ResolveChannelID(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.Cache != nil {
+		if err := r.Cache.Put(ref.String(), id); err != nil {
+			log.Printf("failed to cache channel id for %s: %v", ref, err)
+		}
+	}
+	return id, nil
+}
+
+// CSVChannelIDCache persists resolved channel references as ref,channel_id,resolved_at rows.
+type CSVChannelIDCache struct {
+	Path string
+
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]string
+}
+
+func (c *CSVChannelIDCache) Get(ref string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return "", false, err
+	}
+	id, ok := c.entries[strings.ToLower(ref)]
+	return id, ok, nil
+}
+
+func (c *CSVChannelIDCache) Put(ref, channelID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	if err := ensureFile(c.Path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{ref, channelID, time.Now().Format(time.RFC3339)}); err != nil {
+		return err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	c.entries[strings.ToLower(ref)] = channelID
+	return nil
+}
+
+func (c *CSVChannelIDCache) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.entries = map[string]string{}
+	f, err := os.Open(c.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.loaded = true
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if len(row) < 2 || !isChannelID(strings.TrimSpace(row[1])) {
+			continue
+		}
+		c.entries[strings.ToLower(strings.TrimSpace(row[0]))] = strings.TrimSpace(row[1])
+	}
+	c.loaded = true
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import "testing"
+
+func TestParseChannelRef(t *testing.T) {
+	cases := map[string]ChannelRef{
+		"UCabcdefghijklmnopqrstuv":                              {Kind: ChannelRefID, Value: "UCabcdefghijklmnopqrstuv"},
+		"@sample":                                               {Kind: ChannelRefHandle, Value: "sample"},
+		"https://www.youtube.com/@sample/videos":                {Kind: ChannelRefHandle, Value: "sample"},
+		"youtube.com/channel/UCabcdefghijklmnopqrstuv":          {Kind: ChannelRefID, Value: "UCabcdefghijklmnopqrstuv"},
+		"https://www.youtube.com/c/SampleCustom":                {Kind: ChannelRefCustom, Value: "SampleCustom"},
+		"http://youtube.com/user/legacyname?view_as=subscriber": {Kind: ChannelRefUsername, Value: "legacyname"},
+	}
+	for raw, want := range cases {
+		got, err := ParseChannelRef(raw)
+		if err != nil {
+			t.Fatalf("ParseChannelRef(%q) error: %v", raw, err)
+		}
+		if got != want {
+			t.Fatalf("ParseChannelRef(%q) = %+v, want %+v", raw, got, want)
+		}
+	}
+	for _, raw := range []string{"", "sample", "https://www.youtube.com/watch?v=abc"} {
+		if _, err := ParseChannelRef(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
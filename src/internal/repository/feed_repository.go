@@ -2,6 +2,7 @@ package repository
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,27 +14,105 @@ import (
 )
 
 type FeedRepository interface {
-	Fetch(channelID string) ([]model.VideoDTO, error)
+	// Fetch downloads the channel feed. Non-zero validators make it a conditional request that
+	// fails with ErrFeedNotModified when nothing changed; the returned validators belong to the new response.
+	Fetch(channelID string, validators model.FeedValidators) ([]model.VideoDTO, model.FeedValidators, error)
 }
 
-type RSSFeedRepository struct{}
+var ErrFeedNotModified = errors.New("youtube feed not modified")
 
-func (r *RSSFeedRepository) Fetch(channelID string) ([]model.VideoDTO, error) {
-	u := fmt.Sprintf("https://www.youtube.com/feeds/videos.xml?channel_id=%s", channelID)
-	resp, err := http.Get(u)
+// FeedHTTPError is a non-2xx answer from the feed endpoint.
+type FeedHTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *FeedHTTPError) Error() string {
+	return fmt.Sprintf("youtube feed status %d", e.StatusCode)
+}
+
+const DefaultFeedBaseURL = "https://www.youtube.com"
+
+type RSSFeedRepository struct {
+	Client  *http.Client
+	BaseURL string // defaults to DefaultFeedBaseURL
+}
+
+func (r *RSSFeedRepository) Fetch(channelID string, validators model.FeedValidators) ([]model.VideoDTO, model.FeedValidators, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = DefaultFeedBaseURL
+	}
+	u := fmt.Sprintf("%s/feeds/videos.xml?channel_id=%s", strings.TrimSuffix(base, "/"), url.QueryEscape(channelID))
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
 	if err != nil {
-		return nil, err
+		return nil, model.FeedValidators{}, err
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, model.FeedValidators{}, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, validators, ErrFeedNotModified
+	}
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("youtube feed status %d", resp.StatusCode)
+		return nil, model.FeedValidators{}, &FeedHTTPError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	next := model.FeedValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}
 
 	feed, err := decodeFeed(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, model.FeedValidators{}, err
+	}
+	return feedVideos(feed, channelID), next, nil
+}
+
+// PushedFeed is the body of a WebSub notification: new or updated uploads and deleted videos.
+type PushedFeed struct {
+	Videos  []model.VideoDTO
+	Deleted []model.DeletedVideo
+}
+
+// DecodePushedFeed parses a feed pushed by the hub. The channel comes from each entry's
+// yt:channelId, or from the at:by URI of a deleted entry.
+func DecodePushedFeed(r io.Reader) (PushedFeed, error) {
+	feed, err := decodeFeed(r)
+	if err != nil {
+		return PushedFeed{}, err
 	}
+	out := PushedFeed{Videos: feedVideos(feed, "")}
+	for _, d := range feed.Deleted {
+		deleted := model.DeletedVideo{
+			VideoID:   normalizeVideoID(d.Ref, d.Link.Href),
+			ChannelID: channelIDFromURI(d.By.URI),
+		}
+		if t, err := time.Parse(time.RFC3339Nano, d.When); err == nil {
+			deleted.DeletedAt = t
+		}
+		out.Deleted = append(out.Deleted, deleted)
+	}
+	return out, nil
+}
 
+// feedVideos converts feed entries; channelID is used for entries without yt:channelId.
+func feedVideos(feed *ytFeed, channelID string) []model.VideoDTO {
 	var out []model.VideoDTO
 	for _, entry := range feed.Entries {
 		vid := normalizeVideoID(entry.ID, entry.Link())
@@ -43,16 +122,45 @@ func (r *RSSFeedRepository) Fetch(channelID string) ([]model.VideoDTO, error) {
 				published = t
 			}
 		}
+		chID := channelID
+		if chID == "" {
+			chID = entry.ChannelID
+		}
+		name := entry.Author
+		if name == "" {
+			name = feed.Title
+		}
 		out = append(out, model.VideoDTO{
 			VideoID:     vid,
 			Title:       entry.Title,
+			Description: entry.Description,
 			Link:        entry.Link(),
-			ChannelID:   channelID,
-			ChannelName: feed.Title,
+			ChannelID:   chID,
+			ChannelName: name,
 			PublishedAt: published,
+			Kind:        kindFromLink(entry.Link()),
 		})
 	}
-	return out, nil
+	return out
+}
+
+func channelIDFromURI(uri string) string {
+	if i := strings.LastIndex(uri, "/channel/"); i != -1 {
+		return strings.Trim(uri[i+len("/channel/"):], "/")
+	}
+	return ""
+}
+
+// kindFromLink classifies RSS entries: the feed links Shorts as /shorts/{id} and everything else as /watch?v={id}.
+func kindFromLink(link string) model.VideoKind {
+	switch {
+	case strings.Contains(link, "/shorts/"):
+		return model.VideoKindShort
+	case strings.Contains(link, "/watch"):
+		return model.VideoKindRegular
+	default:
+		return model.VideoKindUnknown
+	}
 }
 
 func normalizeVideoID(guid, link string) string {
@@ -72,9 +180,20 @@ func normalizeVideoID(guid, link string) string {
 }
 
 type ytFeed struct {
-	XMLName xml.Name  `xml:"feed"`
-	Title   string    `xml:"title"`
-	Entries []ytEntry `xml:"entry"`
+	XMLName xml.Name         `xml:"feed"`
+	Title   string           `xml:"title"`
+	Entries []ytEntry        `xml:"entry"`
+	Deleted []ytDeletedEntry `xml:"http://purl.org/atompub/tombstones/1.0 deleted-entry"`
+}
+
+// ytDeletedEntry is the RFC 6721 tombstone the hub pushes when a video is removed.
+type ytDeletedEntry struct {
+	Ref  string `xml:"ref,attr"`
+	When string `xml:"when,attr"`
+	Link ytLink `xml:"link"`
+	By   struct {
+		URI string `xml:"uri"`
+	} `xml:"http://purl.org/atompub/tombstones/1.0 by"`
 }
 
 type ytEntry struct {
@@ -83,12 +202,17 @@ type ytEntry struct {
 	Title     string
 	Links     []ytLink
 	Published string
+	ChannelID string // yt:channelId
+	Author    string // author/name
+	// Description is media:group/media:description.
+	Description string
 }
 
 func (e *ytEntry) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
 	const (
-		atomNS = "http://www.w3.org/2005/Atom"
-		ytNS   = "http://www.youtube.com/xml/schemas/2015"
+		atomNS  = "http://www.w3.org/2005/Atom"
+		ytNS    = "http://www.youtube.com/xml/schemas/2015"
+		mediaNS = "http://search.yahoo.com/mrss/"
 	)
 
 	*e = ytEntry{}
@@ -138,6 +262,22 @@ func (e *ytEntry) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
 				if err := dec.Skip(); err != nil {
 					return err
 				}
+			case t.Name.Space == mediaNS && t.Name.Local == "description":
+				if err := dec.DecodeElement(&e.Description, &t); err != nil {
+					return err
+				}
+			case t.Name.Space == ytNS && t.Name.Local == "channelId":
+				if err := dec.DecodeElement(&e.ChannelID, &t); err != nil {
+					return err
+				}
+			case (t.Name.Space == atomNS || t.Name.Space == "") && t.Name.Local == "author":
+				var author struct {
+					Name string `xml:"name"`
+				}
+				if err := dec.DecodeElement(&author, &t); err != nil {
+					return err
+				}
+				e.Author = author.Name
 			case t.Name.Space == ytNS && t.Name.Local == "videoId":
 				var videoID string
 				if err := dec.DecodeElement(&videoID, &t); err != nil {
@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
 )
 
 const sampleFeed = `<?xml version="1.0" encoding="UTF-8"?>
@@ -38,3 +43,30 @@ func TestDecodeFeed(t *testing.T) {
 		t.Fatalf("expected fallback to entry id, got %q", feed.Entries[1].ID)
 	}
 }
+
+func TestRSSFetchAgainstBaseURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feeds/videos.xml" || r.URL.Query().Get("channel_id") != "UC123" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(sampleFeed))
+	}))
+	defer srv.Close()
+
+	repo := &RSSFeedRepository{Client: srv.Client(), BaseURL: srv.URL}
+	videos, validators, err := repo.Fetch("UC123", model.FeedValidators{})
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if len(videos) != 2 || validators.ETag != `"v1"` {
+		t.Fatalf("unexpected result: %d videos, validators %+v", len(videos), validators)
+	}
+	if _, _, err := repo.Fetch("UC123", validators); !errors.Is(err, ErrFeedNotModified) {
+		t.Fatalf("expected ErrFeedNotModified, got %v", err)
+	}
+}
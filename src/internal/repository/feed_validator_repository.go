@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+// FeedValidatorRepository persists ETag / Last-Modified validators per channel between runs.
+type FeedValidatorRepository interface {
+	Get(channelID string) (model.FeedValidators, error)
+	// Save merges updates into the store and persists it.
+	Save(updates map[string]model.FeedValidators) error
+}
+
+var feedValidatorHeader = []string{"channel_id", "etag", "last_modified", "updated_at"}
+
+// CSVFeedValidatorRepository keeps the validators in memory and rewrites the CSV file on Save.
+type CSVFeedValidatorRepository struct {
+	Path string
+
+	mu      sync.RWMutex
+	entries map[string]feedValidatorRow
+}
+
+type feedValidatorRow struct {
+	validators model.FeedValidators
+	updatedAt  string
+}
+
+func NewCSVFeedValidatorRepository(path string) (*CSVFeedValidatorRepository, error) {
+	r := &CSVFeedValidatorRepository{Path: path, entries: map[string]feedValidatorRow{}}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && row[0] == feedValidatorHeader[0] {
+			continue
+		}
+		if len(row) < 4 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		r.entries[strings.TrimSpace(row[0])] = feedValidatorRow{
+			validators: model.FeedValidators{ETag: row[1], LastModified: row[2]},
+			updatedAt:  row[3],
+		}
+	}
+	return r, nil
+}
+
+func (r *CSVFeedValidatorRepository) Get(channelID string) (model.FeedValidators, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.entries[channelID].validators, nil
+}
+
+func (r *CSVFeedValidatorRepository) Save(updates map[string]model.FeedValidators) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(updates) == 0 {
+		return nil
+	}
+	now := time.Now().Format(time.RFC3339)
+	for channelID, v := range updates {
+		if v.IsZero() {
+			delete(r.entries, channelID)
+			continue
+		}
+		r.entries[channelID] = feedValidatorRow{validators: v, updatedAt: now}
+	}
+
+	ids := make([]string, 0, len(r.entries))
+	for id := range r.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tmp := r.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write(feedValidatorHeader)
+	for _, id := range ids {
+		e := r.entries[id]
+		_ = w.Write([]string{id, e.validators.ETag, e.validators.LastModified, e.updatedAt})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, r.Path)
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+type jsonlChannel struct {
+	ChannelID    string `json:"channel_id"`
+	Category     string `json:"category"`
+	Name         string `json:"name,omitempty"`
+	Enabled      bool   `json:"enabled"`
+	FetchLimit   int    `json:"fetch_limit,omitempty"`
+	IncludeRules string `json:"include_rules,omitempty"`
+	ExcludeRules string `json:"exclude_rules,omitempty"`
+	Routes       string `json:"routes,omitempty"`
+}
+
+type jsonlNotified struct {
+	VideoID     string    `json:"video_id"`
+	ChannelID   string    `json:"channel_id"`
+	PublishedAt time.Time `json:"published_at"`
+	NotifiedAt  time.Time `json:"notified_at"`
+	Category    string    `json:"category,omitempty"`
+}
+
+// JSONLChannelRepository stores one channel per line in a JSON Lines file.
+type JSONLChannelRepository struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (r *JSONLChannelRepository) ListEnabled() ([]model.ChannelDTO, error) {
+	all, err := r.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	var out []model.ChannelDTO
+	for _, ch := range all {
+		if ch.Enabled {
+			out = append(out, ch)
+		}
+	}
+	return out, nil
+}
+
+func (r *JSONLChannelRepository) ListAll() ([]model.ChannelDTO, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []model.ChannelDTO
+	err := readJSONL(r.Path, func(line int, raw []byte) error {
+		var ch jsonlChannel
+		if err := json.Unmarshal(raw, &ch); err != nil {
+			return fmt.Errorf("%s:%d: %w", r.Path, line, err)
+		}
+		out = append(out, model.ChannelDTO(ch))
+		return nil
+	})
+	return out, err
+}
+
+func (r *JSONLChannelRepository) Add(ch model.ChannelDTO) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return appendJSONL(r.Path, jsonlChannel(ch))
+}
+
+// JSONLNotifiedRepository keeps a JSON Lines history indexed in memory, like CSVNotifiedRepository.
+type JSONLNotifiedRepository struct {
+	Path string
+
+	mu      sync.RWMutex
+	records map[string]model.NotifiedRecord
+	order   []string
+	latest  map[string]model.NotifiedRecord
+}
+
+func NewJSONLNotifiedRepository(path string) (*JSONLNotifiedRepository, error) {
+	r := &JSONLNotifiedRepository{Path: path, records: map[string]model.NotifiedRecord{}, latest: map[string]model.NotifiedRecord{}}
+	err := readJSONLLog(path, func(line int, raw []byte) error {
+		var rec jsonlNotified
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if _, dup := r.records[rec.VideoID]; dup || rec.VideoID == "" {
+			return nil
+		}
+		r.records[rec.VideoID] = model.NotifiedRecord(rec)
+		r.order = append(r.order, rec.VideoID)
+		indexLatest(r.latest, model.NotifiedRecord(rec))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *JSONLNotifiedRepository) Has(videoID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.records[videoID]
+	return ok, nil
+}
+
+func (r *JSONLNotifiedRepository) HasChannel(channelID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.latest[channelID]
+	return ok, nil
+}
+
+func (r *JSONLNotifiedRepository) LatestForChannel(channelID string) (model.NotifiedRecord, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rec, ok := r.latest[channelID]
+	return rec, ok, nil
+}
+
+func (r *JSONLNotifiedRepository) Append(rec model.NotifiedRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[rec.VideoID]; ok {
+		return nil
+	}
+	rec.PublishedAt, rec.NotifiedAt = rec.PublishedAt.UTC(), rec.NotifiedAt.UTC()
+	if err := appendJSONL(r.Path, jsonlNotified(rec)); err != nil {
+		return err
+	}
+	r.records[rec.VideoID] = rec
+	r.order = append(r.order, rec.VideoID)
+	indexLatest(r.latest, rec)
+	return nil
+}
+
+func (r *JSONLNotifiedRepository) ListAll() ([]model.NotifiedRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.NotifiedRecord, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.records[id])
+	}
+	return out, nil
+}
+
+// readJSONL calls fn for every non-empty line; a missing file has no lines.
+func readJSONL(path string, fn func(line int, raw []byte) error) error {
+	err := scanJSONL(path, fn)
+	var torn *tornLineError
+	if errors.As(err, &torn) {
+		return torn.err
+	}
+	return err
+}
+
+// readJSONLLog is readJSONL for append-only logs. A malformed last line is what a crash
+// mid-append leaves behind, so it is logged and cut off (the next append would otherwise
+// land on the same line); a malformed line anywhere else is still an error.
+func readJSONLLog(path string, fn func(line int, raw []byte) error) error {
+	err := scanJSONL(path, fn)
+	var torn *tornLineError
+	if !errors.As(err, &torn) {
+		return err
+	}
+	log.Printf("dropping torn last line of %s: %v", path, torn.err)
+	return os.Truncate(path, torn.offset)
+}
+
+// tornLineError reports that fn rejected the last line of the file, which starts at offset.
+type tornLineError struct {
+	offset int64
+	err    error
+}
+
+func (e *tornLineError) Error() string { return e.err.Error() }
+
+func scanJSONL(path string, fn func(line int, raw []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	line := 0
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		start := offset
+		offset += int64(len(raw))
+		line++
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 {
+			if err := fn(line, trimmed); err != nil {
+				rest, restErr := io.ReadAll(reader)
+				if restErr == nil && len(bytes.TrimSpace(rest)) == 0 {
+					return &tornLineError{offset: start, err: err}
+				}
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func appendJSONL(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
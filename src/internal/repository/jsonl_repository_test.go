@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+func TestJSONLNotifiedRepositoryDropsTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notified.jsonl")
+	content := `{"video_id":"VIDEO1","channel_id":"UC1","published_at":"2024-01-01T00:00:00Z","notified_at":"2024-01-01T01:00:00Z"}` + "\n" +
+		`{"video_id":"VIDEO2","channel_id":"UC1","publ`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewJSONLNotifiedRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONLNotifiedRepository error: %v", err)
+	}
+	if ok, _ := repo.Has("VIDEO1"); !ok {
+		t.Fatalf("expected VIDEO1 to be loaded")
+	}
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := repo.Append(model.NotifiedRecord{VideoID: "VIDEO3", ChannelID: "UC1", PublishedAt: now, NotifiedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewJSONLNotifiedRepository(path)
+	if err != nil {
+		t.Fatalf("reload after append: %v", err)
+	}
+	for id, want := range map[string]bool{"VIDEO1": true, "VIDEO2": false, "VIDEO3": true} {
+		if got, _ := reloaded.Has(id); got != want {
+			t.Fatalf("Has(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestJSONLNotifiedRepositoryRejectsCorruptMiddleLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notified.jsonl")
+	content := `{"video_id":"VIDEO1","channel_id":"UC1","publ` + "\n" +
+		`{"video_id":"VIDEO2","channel_id":"UC1","published_at":"2024-01-01T00:00:00Z","notified_at":"2024-01-01T01:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJSONLNotifiedRepository(path); err == nil {
+		t.Fatalf("expected an error for a malformed line in the middle of the file")
+	}
+}
+
+func TestJSONLOutboxRepositoryDropsTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	content := `{"video_id":"VIDEO1","category":"news","state":"pending"}` + "\n" +
+		`{"video_id":"VIDEO1","category":"news","sta`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewJSONLOutboxRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONLOutboxRepository error: %v", err)
+	}
+	entry, ok, err := repo.Get("VIDEO1", "news")
+	if err != nil || !ok || entry.State != model.OutboxPending {
+		t.Fatalf("Get = %+v, %v, %v; want the pending entry", entry, ok, err)
+	}
+	if err := repo.Save(model.OutboxEntry{Video: model.VideoDTO{VideoID: "VIDEO2"}, Category: "news", State: model.OutboxPending}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJSONLOutboxRepository(path); err != nil {
+		t.Fatalf("reload after save: %v", err)
+	}
+}
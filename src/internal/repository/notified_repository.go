@@ -2,46 +2,212 @@ package repository
 
 import (
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
 )
 
 type NotifiedRepository interface {
 	Has(videoID string) (bool, error)
-	Append(videoID, channelID string, publishedAt, notifiedAt time.Time) error
+	// HasChannel reports whether any video of the channel is in the history, i.e. whether the
+	// channel has been processed before. Records with a zero PublishedAt (tombstones of videos
+	// deleted before they were seen) are ignored here and by LatestForChannel.
+	HasChannel(channelID string) (bool, error)
+	// LatestForChannel returns the channel's history entry with the newest publish time; ok is
+	// false when the channel has none.
+	LatestForChannel(channelID string) (rec model.NotifiedRecord, ok bool, err error)
+	Append(rec model.NotifiedRecord) error
 }
 
-type CSVNotifiedRepository struct{ Path string }
+// NotifiedStore is a NotifiedRepository whose whole history can be read back, e.g. for migrations.
+type NotifiedStore interface {
+	NotifiedRepository
+	ListAll() ([]model.NotifiedRecord, error)
+}
 
-func (r *CSVNotifiedRepository) Has(videoID string) (bool, error) {
+var notifiedHeader = []string{"video_id", "channel_id", "published_at", "notified_at", "category"}
+
+// notifiedMinFields is the column count of rows written before the category column existed.
+const notifiedMinFields = 4
+
+// CSVNotifiedRepository keeps notified.csv indexed in memory. The file is read once by
+// NewCSVNotifiedRepository; Append writes through to the file and updates the index.
+type CSVNotifiedRepository struct {
+	Path string
+
+	mu      sync.RWMutex // fetch workers check and mark videos concurrently
+	records map[string]model.NotifiedRecord
+	order   []string
+	latest  map[string]model.NotifiedRecord // newest published video per channel
+	report  NotifiedLoadReport
+}
+
+// NotifiedLoadReport describes what NewCSVNotifiedRepository found in the history file.
+type NotifiedLoadReport struct {
+	Rows           int
+	HasHeader      bool
+	Duplicates     int
+	DuplicateLines []int
+	Malformed      int
+	MalformedLines []int
+}
+
+func NewCSVNotifiedRepository(path string) (*CSVNotifiedRepository, error) {
+	r := &CSVNotifiedRepository{Path: path, records: map[string]model.NotifiedRecord{}, latest: map[string]model.NotifiedRecord{}}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CSVNotifiedRepository) load() error {
 	f, err := os.Open(r.Path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return false, nil
+			return nil
 		}
-		return false, err
+		return err
 	}
 	defer f.Close()
 
 	cr := csv.NewReader(f)
 	cr.FieldsPerRecord = -1
-	rows, err := cr.ReadAll()
-	if err != nil {
-		return false, err
-	}
-	for i, row := range rows {
-		if i == 0 {
-			// ヘッダ考慮せずシンプルに走査（ヘッダ行があっても video_id と一致することはほぼない）
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if perr, ok := err.(*csv.ParseError); ok {
+				r.report.Malformed++
+				r.report.MalformedLines = append(r.report.MalformedLines, perr.StartLine)
+				continue
+			}
+			return err
 		}
-		if len(row) > 0 && row[0] == videoID {
-			return true, nil
+		line, _ := cr.FieldPos(0)
+		if r.report.Rows == 0 && !r.report.HasHeader && isNotifiedHeader(row) {
+			r.report.HasHeader = true
+			continue
+		}
+		if !validNotifiedRow(row) {
+			r.report.Malformed++
+			r.report.MalformedLines = append(r.report.MalformedLines, line)
+			continue
+		}
+		r.report.Rows++
+		rec := parseNotifiedRow(row)
+		if _, dup := r.records[rec.VideoID]; dup {
+			r.report.Duplicates++
+			r.report.DuplicateLines = append(r.report.DuplicateLines, line)
+			continue
+		}
+		r.index(rec)
+	}
+	return nil
+}
+
+func (r *CSVNotifiedRepository) index(rec model.NotifiedRecord) {
+	r.records[rec.VideoID] = rec
+	r.order = append(r.order, rec.VideoID)
+	indexLatest(r.latest, rec)
+}
+
+func indexLatest(latest map[string]model.NotifiedRecord, rec model.NotifiedRecord) {
+	if rec.PublishedAt.IsZero() {
+		return
+	}
+	if cur, ok := latest[rec.ChannelID]; !ok || rec.PublishedAt.After(cur.PublishedAt) {
+		latest[rec.ChannelID] = rec
+	}
+}
+
+// parseNotifiedRow converts a row that passed validNotifiedRow.
+func parseNotifiedRow(row []string) model.NotifiedRecord {
+	published, _ := time.Parse(time.RFC3339, strings.TrimSpace(row[2]))
+	notified, _ := time.Parse(time.RFC3339, strings.TrimSpace(row[3]))
+	rec := model.NotifiedRecord{
+		VideoID:     strings.TrimSpace(row[0]),
+		ChannelID:   strings.TrimSpace(row[1]),
+		PublishedAt: published,
+		NotifiedAt:  notified,
+	}
+	if len(row) > notifiedMinFields {
+		rec.Category = strings.TrimSpace(row[4])
+	}
+	return rec
+}
+
+func isNotifiedHeader(row []string) bool {
+	return len(row) > 0 && strings.EqualFold(strings.TrimSpace(row[0]), notifiedHeader[0])
+}
+
+func validNotifiedRow(row []string) bool {
+	if len(row) < notifiedMinFields || strings.TrimSpace(row[0]) == "" {
+		return false
+	}
+	for _, raw := range row[2:4] {
+		if _, err := time.Parse(time.RFC3339, strings.TrimSpace(raw)); err != nil {
+			return false
 		}
 	}
-	return false, nil
+	return true
+}
+
+// Report returns what was found while loading the history file.
+func (r *CSVNotifiedRepository) Report() NotifiedLoadReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.report
 }
 
-func (r *CSVNotifiedRepository) Append(videoID, channelID string, publishedAt, notifiedAt time.Time) error {
-	if err := ensureFile(r.Path); err != nil {
+func (r *CSVNotifiedRepository) Has(videoID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.records[videoID]
+	return ok, nil
+}
+
+func (r *CSVNotifiedRepository) HasChannel(channelID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.latest[channelID]
+	return ok, nil
+}
+
+func (r *CSVNotifiedRepository) LatestForChannel(channelID string) (model.NotifiedRecord, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rec, ok := r.latest[channelID]
+	return rec, ok, nil
+}
+
+// ListAll returns the history in file order, without duplicates.
+func (r *CSVNotifiedRepository) ListAll() ([]model.NotifiedRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.NotifiedRecord, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.records[id])
+	}
+	return out, nil
+}
+
+func (r *CSVNotifiedRepository) Append(rec model.NotifiedRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.records == nil {
+		return fmt.Errorf("notified repository %s is not loaded", r.Path)
+	}
+	if _, ok := r.records[rec.VideoID]; ok {
+		return nil
+	}
+	if err := ensureCSVWithHeader(r.Path, notifiedHeader); err != nil {
 		return err
 	}
 	f, err := os.OpenFile(r.Path, os.O_APPEND|os.O_WRONLY, 0644)
@@ -51,15 +217,21 @@ func (r *CSVNotifiedRepository) Append(videoID, channelID string, publishedAt, n
 	defer f.Close()
 
 	w := csv.NewWriter(f)
-	defer w.Flush()
-
-	rec := []string{
-		videoID,
-		channelID,
-		publishedAt.Format(time.RFC3339),
-		notifiedAt.Format(time.RFC3339),
+	if err := w.Write([]string{
+		rec.VideoID,
+		rec.ChannelID,
+		rec.PublishedAt.Format(time.RFC3339),
+		rec.NotifiedAt.Format(time.RFC3339),
+		rec.Category,
+	}); err != nil {
+		return err
 	}
-	return w.Write(rec)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	r.index(rec)
+	return nil
 }
 
 func ensureFile(path string) error {
@@ -72,3 +244,25 @@ func ensureFile(path string) error {
 	}
 	return nil
 }
+
+// ensureCSVWithHeader creates path with a header row when it is missing or empty.
+func ensureCSVWithHeader(path string, header []string) error {
+	info, err := os.Stat(path)
+	if err == nil && info.Size() > 0 {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
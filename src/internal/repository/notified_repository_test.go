@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+func TestCSVNotifiedRepositoryLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notified.csv")
+	content := "video_id,channel_id,published_at,notified_at\n" +
+		"VIDEO1,UC1,2024-01-01T00:00:00Z,2024-01-01T01:00:00Z\n" +
+		"VIDEO2,UC1,2024-01-02T00:00:00Z,2024-01-02T01:00:00Z\n" +
+		"VIDEO1,UC1,2024-01-01T00:00:00Z,2024-01-03T01:00:00Z\n" +
+		"VIDEO3,UC1\n" +
+		"VIDEO4,UC1,yesterday,2024-01-02T01:00:00Z\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewCSVNotifiedRepository(path)
+	if err != nil {
+		t.Fatalf("NewCSVNotifiedRepository error: %v", err)
+	}
+	report := repo.Report()
+	if !report.HasHeader || report.Rows != 3 || report.Duplicates != 1 || report.Malformed != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if !reflect.DeepEqual(report.DuplicateLines, []int{4}) || !reflect.DeepEqual(report.MalformedLines, []int{5, 6}) {
+		t.Fatalf("unexpected report lines %+v", report)
+	}
+	for id, want := range map[string]bool{"VIDEO1": true, "VIDEO2": true, "VIDEO3": false, "video_id": false} {
+		if got, _ := repo.Has(id); got != want {
+			t.Fatalf("Has(%q) = %v, want %v", id, got, want)
+		}
+	}
+
+	now := time.Now()
+	if err := repo.Append(model.NotifiedRecord{VideoID: "VIDEO5", ChannelID: "UC1", PublishedAt: now, NotifiedAt: now}); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+	if ok, _ := repo.Has("VIDEO5"); !ok {
+		t.Fatalf("expected appended video to be indexed")
+	}
+	reloaded, err := NewCSVNotifiedRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := reloaded.Has("VIDEO5"); !ok {
+		t.Fatalf("expected appended video to be persisted")
+	}
+}
+
+func TestCSVNotifiedRepositoryWritesHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notified.csv")
+	repo, err := NewCSVNotifiedRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.Append(model.NotifiedRecord{VideoID: "VIDEO1", ChannelID: "UC1", PublishedAt: now, NotifiedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "video_id,channel_id,published_at,notified_at,category\nVIDEO1,UC1,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,\n"
+	if string(b) != want {
+		t.Fatalf("unexpected file content:\n%s", b)
+	}
+}
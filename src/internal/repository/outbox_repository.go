@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+// OutboxRepository persists notifications around dispatch so a crash can be reconciled on the next run.
+// Entries are keyed by video ID and category.
+type OutboxRepository interface {
+	Get(videoID, category string) (model.OutboxEntry, bool, error)
+	Save(entry model.OutboxEntry) error
+	Remove(videoID, category string) error
+	List() ([]model.OutboxEntry, error)
+}
+
+type outboxRecord struct {
+	VideoID   string            `json:"video_id"`
+	Category  string            `json:"category"`
+	State     model.OutboxState `json:"state,omitempty"`
+	Attempts  int               `json:"attempts,omitempty"`
+	LastError string            `json:"last_error,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Failures  int               `json:"failures,omitempty"`
+	NextRetry *time.Time        `json:"next_retry_at,omitempty"`
+	Video     *model.VideoDTO   `json:"video,omitempty"`
+	Removed   bool              `json:"removed,omitempty"`
+}
+
+func outboxKey(videoID, category string) string {
+	return videoID + "\x00" + strings.ToLower(category)
+}
+
+func toOutboxRecord(e model.OutboxEntry) outboxRecord {
+	v := e.Video
+	rec := outboxRecord{
+		VideoID:   v.VideoID,
+		Category:  strings.ToLower(e.Category),
+		State:     e.State,
+		Attempts:  e.Attempts,
+		LastError: e.LastError,
+		UpdatedAt: e.UpdatedAt.UTC(),
+		Failures:  e.Failures,
+		Video:     &v,
+	}
+	if !e.NextRetryAt.IsZero() {
+		next := e.NextRetryAt.UTC()
+		rec.NextRetry = &next
+	}
+	return rec
+}
+
+func (r outboxRecord) entry() model.OutboxEntry {
+	e := model.OutboxEntry{
+		Category:  r.Category,
+		State:     r.State,
+		Attempts:  r.Attempts,
+		LastError: r.LastError,
+		UpdatedAt: r.UpdatedAt,
+		Failures:  r.Failures,
+	}
+	if r.NextRetry != nil {
+		e.NextRetryAt = *r.NextRetry
+	}
+	if r.Video != nil {
+		e.Video = *r.Video
+	}
+	return e
+}
+
+// JSONLOutboxRepository is an append-only JSON Lines log of outbox snapshots; the last line per key wins.
+// The log is compacted whenever it is opened.
+type JSONLOutboxRepository struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]outboxRecord
+}
+
+func NewJSONLOutboxRepository(path string) (*JSONLOutboxRepository, error) {
+	r := &JSONLOutboxRepository{Path: path, entries: map[string]outboxRecord{}}
+	lines := 0
+	err := readJSONLLog(path, func(line int, raw []byte) error {
+		var rec outboxRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		lines++
+		key := outboxKey(rec.VideoID, rec.Category)
+		if rec.Removed {
+			delete(r.entries, key)
+			return nil
+		}
+		r.entries[key] = rec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lines > len(r.entries) {
+		if err := r.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *JSONLOutboxRepository) Get(videoID, category string) (model.OutboxEntry, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.entries[outboxKey(videoID, category)]
+	if !ok {
+		return model.OutboxEntry{}, false, nil
+	}
+	return rec.entry(), true, nil
+}
+
+func (r *JSONLOutboxRepository) Save(entry model.OutboxEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := toOutboxRecord(entry)
+	if err := appendJSONL(r.Path, rec); err != nil {
+		return err
+	}
+	r.entries[outboxKey(rec.VideoID, rec.Category)] = rec
+	return nil
+}
+
+func (r *JSONLOutboxRepository) Remove(videoID, category string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := outboxKey(videoID, category)
+	if _, ok := r.entries[key]; !ok {
+		return nil
+	}
+	rec := outboxRecord{VideoID: videoID, Category: strings.ToLower(category), UpdatedAt: time.Now().UTC(), Removed: true}
+	if err := appendJSONL(r.Path, rec); err != nil {
+		return err
+	}
+	delete(r.entries, key)
+	return nil
+}
+
+// List returns the live entries, oldest update first.
+func (r *JSONLOutboxRepository) List() ([]model.OutboxEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortedOutbox(r.entries), nil
+}
+
+func sortedOutbox(entries map[string]outboxRecord) []model.OutboxEntry {
+	out := make([]model.OutboxEntry, 0, len(entries))
+	for _, rec := range entries {
+		out = append(out, rec.entry())
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].UpdatedAt.Equal(out[j].UpdatedAt) {
+			return out[i].UpdatedAt.Before(out[j].UpdatedAt)
+		}
+		return out[i].Video.VideoID < out[j].Video.VideoID
+	})
+	return out
+}
+
+// compact rewrites the log with one line per live entry via a temp file and rename.
+func (r *JSONLOutboxRepository) compact() error {
+	tmp := r.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range sortedOutbox(r.entries) {
+		if err := enc.Encode(toOutboxRecord(e)); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, r.Path)
+}
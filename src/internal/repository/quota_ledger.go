@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaLedger persists the Data API units spent per quota day across runs. Google resets the
+// daily quota at midnight Pacific time, so days are keyed in America/Los_Angeles.
+type QuotaLedger interface {
+	Charge(endpoint string, units int) error
+	Today() (QuotaUsage, error)
+	// Commit writes the charges made since the last Commit.
+	Commit() error
+}
+
+type QuotaUsage struct {
+	Day        string // YYYY-MM-DD in Pacific time
+	Units      int
+	Requests   int
+	ByEndpoint map[string]int // units per endpoint
+}
+
+var quotaLedgerHeader = []string{"day", "endpoint", "requests", "units"}
+
+// quotaLedgerRetention is how many quota days are kept in the file for reference.
+const quotaLedgerRetention = 31
+
+type quotaLedgerRow struct {
+	requests int
+	units    int
+}
+
+// CSVQuotaLedger keeps the ledger in memory and rewrites the CSV file on Commit.
+type CSVQuotaLedger struct {
+	Path string
+
+	mu    sync.Mutex
+	days  map[string]map[string]quotaLedgerRow
+	dirty bool // charged since the last Commit
+	now   func() time.Time
+}
+
+func NewCSVQuotaLedger(path string) (*CSVQuotaLedger, error) {
+	l := &CSVQuotaLedger{Path: path, days: map[string]map[string]quotaLedgerRow{}, now: time.Now}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && row[0] == quotaLedgerHeader[0] {
+			continue
+		}
+		if len(row) < 4 {
+			continue
+		}
+		requests, err1 := strconv.Atoi(strings.TrimSpace(row[2]))
+		units, err2 := strconv.Atoi(strings.TrimSpace(row[3]))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%s line %d: invalid quota row %v", path, i+1, row)
+		}
+		day, endpoint := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		if l.days[day] == nil {
+			l.days[day] = map[string]quotaLedgerRow{}
+		}
+		l.days[day][endpoint] = quotaLedgerRow{requests: requests, units: units}
+	}
+	return l, nil
+}
+
+func (l *CSVQuotaLedger) Charge(endpoint string, units int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	day := QuotaDay(l.now())
+	if l.days[day] == nil {
+		l.days[day] = map[string]quotaLedgerRow{}
+	}
+	row := l.days[day][endpoint]
+	row.requests++
+	row.units += units
+	l.days[day][endpoint] = row
+	l.dirty = true
+	return nil
+}
+
+func (l *CSVQuotaLedger) Commit() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.dirty {
+		return nil
+	}
+	if err := l.persist(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+func (l *CSVQuotaLedger) Today() (QuotaUsage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	usage := QuotaUsage{Day: QuotaDay(l.now()), ByEndpoint: map[string]int{}}
+	for endpoint, row := range l.days[usage.Day] {
+		usage.Units += row.units
+		usage.Requests += row.requests
+		usage.ByEndpoint[endpoint] = row.units
+	}
+	return usage, nil
+}
+
+func (l *CSVQuotaLedger) persist() error {
+	days := make([]string, 0, len(l.days))
+	for day := range l.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	if len(days) > quotaLedgerRetention {
+		for _, day := range days[:len(days)-quotaLedgerRetention] {
+			delete(l.days, day)
+		}
+		days = days[len(days)-quotaLedgerRetention:]
+	}
+
+	tmp := l.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write(quotaLedgerHeader)
+	for _, day := range days {
+		endpoints := make([]string, 0, len(l.days[day]))
+		for endpoint := range l.days[day] {
+			endpoints = append(endpoints, endpoint)
+		}
+		sort.Strings(endpoints)
+		for _, endpoint := range endpoints {
+			row := l.days[day][endpoint]
+			_ = w.Write([]string{day, endpoint, strconv.Itoa(row.requests), strconv.Itoa(row.units)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, l.Path)
+}
+
+// pacific is loaded lazily so an embedded time/tzdata in the main package is registered first.
+var pacific = sync.OnceValue(func() *time.Location {
+	if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
+		return loc
+	}
+	// Without tzdata fall back to standard time; the day boundary is then off by an hour during DST.
+	return time.FixedZone("PST", -8*60*60)
+})
+
+// QuotaDay returns the Data API quota day that t falls in.
+func QuotaDay(t time.Time) string {
+	return t.In(pacific()).Format("2006-01-02")
+}
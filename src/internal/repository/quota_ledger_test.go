@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaLedgerResetsAtPacificMidnight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota_ledger.csv")
+	ledger, err := NewCSVQuotaLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 06:59 UTC on 2024-07-02 is 23:59 PDT on 2024-07-01.
+	now := time.Date(2024, 7, 2, 6, 59, 0, 0, time.UTC)
+	ledger.now = func() time.Time { return now }
+	for _, endpoint := range []string{endpointPlaylistItems, endpointVideos, endpointPlaylistItems} {
+		if err := ledger.Charge(endpoint, quotaCost(endpoint)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("charges must stay in memory until Commit, stat error: %v", err)
+	}
+	if err := ledger.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewCSVQuotaLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.now = func() time.Time { return now }
+	usage, err := reloaded.Today()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Day != "2024-07-01" || usage.Units != 3 || usage.ByEndpoint[endpointPlaylistItems] != 2 {
+		t.Fatalf("unexpected usage after reload: %+v", usage)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if usage, _ := reloaded.Today(); usage.Day != "2024-07-02" || usage.Units != 0 {
+		t.Fatalf("expected a fresh quota day, got %+v", usage)
+	}
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+
+	_ "modernc.org/sqlite" // pure-Go driver registered as "sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS channels (
+	channel_id  TEXT PRIMARY KEY,
+	category    TEXT NOT NULL,
+	name        TEXT NOT NULL DEFAULT '',
+	enabled     INTEGER NOT NULL DEFAULT 1,
+	fetch_limit INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS notified (
+	video_id     TEXT PRIMARY KEY,
+	channel_id   TEXT NOT NULL,
+	published_at TEXT NOT NULL,
+	notified_at  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_notified_channel_id ON notified (channel_id);
+CREATE INDEX IF NOT EXISTS idx_notified_channel_published ON notified (channel_id, published_at);
+CREATE INDEX IF NOT EXISTS idx_notified_notified_at ON notified (notified_at);
+CREATE TABLE IF NOT EXISTS outbox (
+	video_id   TEXT NOT NULL,
+	category   TEXT NOT NULL,
+	state      TEXT NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	video      TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	PRIMARY KEY (video_id, category)
+);
+`
+
+// sqliteMigrations are applied in order on top of sqliteSchema; "duplicate column" errors mean the
+// database already has the change.
+var sqliteMigrations = []string{
+	`ALTER TABLE outbox ADD COLUMN failures INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE outbox ADD COLUMN next_retry_at TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE channels ADD COLUMN include_rules TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE channels ADD COLUMN exclude_rules TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE notified ADD COLUMN category TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE channels ADD COLUMN routes TEXT NOT NULL DEFAULT ''`,
+}
+
+// OpenSQLite opens (creating if needed) the database at path and applies the schema.
+func OpenSQLite(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising through one connection avoids SQLITE_BUSY between fetch workers.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("apply sqlite schema: %w", err)
+	}
+	for _, stmt := range sqliteMigrations {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			db.Close()
+			return nil, fmt.Errorf("apply sqlite migration %q: %w", stmt, err)
+		}
+	}
+	return db, nil
+}
+
+type SQLiteChannelRepository struct{ DB *sql.DB }
+
+func (r *SQLiteChannelRepository) ListEnabled() ([]model.ChannelDTO, error) {
+	rows, err := r.DB.Query(`SELECT channel_id, category, name, fetch_limit, include_rules, exclude_rules, routes FROM channels WHERE enabled = 1 ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ChannelDTO
+	for rows.Next() {
+		ch := model.ChannelDTO{Enabled: true}
+		if err := rows.Scan(&ch.ChannelID, &ch.Category, &ch.Name, &ch.FetchLimit, &ch.IncludeRules, &ch.ExcludeRules, &ch.Routes); err != nil {
+			return nil, err
+		}
+		out = append(out, ch)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteChannelRepository) ListAll() ([]model.ChannelDTO, error) {
+	rows, err := r.DB.Query(`SELECT channel_id, category, name, enabled, fetch_limit, include_rules, exclude_rules, routes FROM channels ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ChannelDTO
+	for rows.Next() {
+		var ch model.ChannelDTO
+		if err := rows.Scan(&ch.ChannelID, &ch.Category, &ch.Name, &ch.Enabled, &ch.FetchLimit, &ch.IncludeRules, &ch.ExcludeRules, &ch.Routes); err != nil {
+			return nil, err
+		}
+		out = append(out, ch)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteChannelRepository) Add(ch model.ChannelDTO) error {
+	_, err := r.DB.Exec(
+		`INSERT INTO channels (channel_id, category, name, enabled, fetch_limit, include_rules, exclude_rules, routes) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		ch.ChannelID, ch.Category, ch.Name, ch.Enabled, ch.FetchLimit, ch.IncludeRules, ch.ExcludeRules, ch.Routes,
+	)
+	return err
+}
+
+type SQLiteNotifiedRepository struct{ DB *sql.DB }
+
+// zeroTime is how a zero PublishedAt (a tombstone) is stored.
+var zeroTime = time.Time{}.Format(time.RFC3339)
+
+func (r *SQLiteNotifiedRepository) Has(videoID string) (bool, error) {
+	var one int
+	err := r.DB.QueryRow(`SELECT 1 FROM notified WHERE video_id = ?`, videoID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SQLiteNotifiedRepository) HasChannel(channelID string) (bool, error) {
+	var one int
+	err := r.DB.QueryRow(`SELECT 1 FROM notified WHERE channel_id = ? AND published_at > ? LIMIT 1`, channelID, zeroTime).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SQLiteNotifiedRepository) LatestForChannel(channelID string) (model.NotifiedRecord, bool, error) {
+	var (
+		rec                   model.NotifiedRecord
+		published, notifiedAt string
+	)
+	err := r.DB.QueryRow(
+		`SELECT video_id, channel_id, published_at, notified_at, category FROM notified WHERE channel_id = ? AND published_at > ? ORDER BY published_at DESC LIMIT 1`,
+		channelID, zeroTime,
+	).Scan(&rec.VideoID, &rec.ChannelID, &published, &notifiedAt, &rec.Category)
+	if err == sql.ErrNoRows {
+		return model.NotifiedRecord{}, false, nil
+	}
+	if err != nil {
+		return model.NotifiedRecord{}, false, err
+	}
+	if rec.PublishedAt, err = time.Parse(time.RFC3339, published); err != nil {
+		return model.NotifiedRecord{}, false, fmt.Errorf("notified %s: %w", rec.VideoID, err)
+	}
+	if rec.NotifiedAt, err = time.Parse(time.RFC3339, notifiedAt); err != nil {
+		return model.NotifiedRecord{}, false, fmt.Errorf("notified %s: %w", rec.VideoID, err)
+	}
+	return rec, true, nil
+}
+
+func (r *SQLiteNotifiedRepository) Append(rec model.NotifiedRecord) error {
+	// Timestamps are stored in UTC so that text ordering matches time ordering.
+	_, err := r.DB.Exec(
+		`INSERT OR IGNORE INTO notified (video_id, channel_id, published_at, notified_at, category) VALUES (?, ?, ?, ?, ?)`,
+		rec.VideoID, rec.ChannelID, rec.PublishedAt.UTC().Format(time.RFC3339), rec.NotifiedAt.UTC().Format(time.RFC3339), rec.Category,
+	)
+	return err
+}
+
+func (r *SQLiteNotifiedRepository) ListAll() ([]model.NotifiedRecord, error) {
+	rows, err := r.DB.Query(`SELECT video_id, channel_id, published_at, notified_at, category FROM notified ORDER BY notified_at, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.NotifiedRecord
+	for rows.Next() {
+		var (
+			rec                   model.NotifiedRecord
+			published, notifiedAt string
+		)
+		if err := rows.Scan(&rec.VideoID, &rec.ChannelID, &published, &notifiedAt, &rec.Category); err != nil {
+			return nil, err
+		}
+		if rec.PublishedAt, err = time.Parse(time.RFC3339, published); err != nil {
+			return nil, fmt.Errorf("notified %s: %w", rec.VideoID, err)
+		}
+		if rec.NotifiedAt, err = time.Parse(time.RFC3339, notifiedAt); err != nil {
+			return nil, fmt.Errorf("notified %s: %w", rec.VideoID, err)
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+type SQLiteOutboxRepository struct{ DB *sql.DB }
+
+func (r *SQLiteOutboxRepository) Get(videoID, category string) (model.OutboxEntry, bool, error) {
+	row := r.DB.QueryRow(`SELECT category, state, attempts, last_error, video, updated_at, failures, next_retry_at FROM outbox WHERE video_id = ? AND category = ?`,
+		videoID, strings.ToLower(category))
+	e, err := scanOutbox(row)
+	if err == sql.ErrNoRows {
+		return model.OutboxEntry{}, false, nil
+	}
+	if err != nil {
+		return model.OutboxEntry{}, false, err
+	}
+	return e, true, nil
+}
+
+func (r *SQLiteOutboxRepository) Save(entry model.OutboxEntry) error {
+	video, err := json.Marshal(entry.Video)
+	if err != nil {
+		return err
+	}
+	nextRetry := ""
+	if !entry.NextRetryAt.IsZero() {
+		nextRetry = entry.NextRetryAt.UTC().Format(time.RFC3339Nano)
+	}
+	_, err = r.DB.Exec(`INSERT INTO outbox (video_id, category, state, attempts, last_error, video, updated_at, failures, next_retry_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (video_id, category) DO UPDATE SET
+			state = excluded.state, attempts = excluded.attempts, last_error = excluded.last_error,
+			video = excluded.video, updated_at = excluded.updated_at,
+			failures = excluded.failures, next_retry_at = excluded.next_retry_at`,
+		entry.Video.VideoID, strings.ToLower(entry.Category), string(entry.State), entry.Attempts, entry.LastError,
+		string(video), entry.UpdatedAt.UTC().Format(time.RFC3339Nano), entry.Failures, nextRetry,
+	)
+	return err
+}
+
+func (r *SQLiteOutboxRepository) Remove(videoID, category string) error {
+	_, err := r.DB.Exec(`DELETE FROM outbox WHERE video_id = ? AND category = ?`, videoID, strings.ToLower(category))
+	return err
+}
+
+func (r *SQLiteOutboxRepository) List() ([]model.OutboxEntry, error) {
+	rows, err := r.DB.Query(`SELECT category, state, attempts, last_error, video, updated_at, failures, next_retry_at FROM outbox ORDER BY updated_at, video_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.OutboxEntry
+	for rows.Next() {
+		e, err := scanOutbox(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func scanOutbox(row interface{ Scan(...any) error }) (model.OutboxEntry, error) {
+	var (
+		e                            model.OutboxEntry
+		state, video, upd, nextRetry string
+	)
+	if err := row.Scan(&e.Category, &state, &e.Attempts, &e.LastError, &video, &upd, &e.Failures, &nextRetry); err != nil {
+		return model.OutboxEntry{}, err
+	}
+	e.State = model.OutboxState(state)
+	if err := json.Unmarshal([]byte(video), &e.Video); err != nil {
+		return model.OutboxEntry{}, fmt.Errorf("outbox video: %w", err)
+	}
+	t, err := time.Parse(time.RFC3339Nano, upd)
+	if err != nil {
+		return model.OutboxEntry{}, fmt.Errorf("outbox updated_at: %w", err)
+	}
+	e.UpdatedAt = t
+	if nextRetry != "" {
+		if e.NextRetryAt, err = time.Parse(time.RFC3339Nano, nextRetry); err != nil {
+			return model.OutboxEntry{}, fmt.Errorf("outbox next_retry_at: %w", err)
+		}
+	}
+	return e, nil
+}
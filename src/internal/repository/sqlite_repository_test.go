@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+func TestSQLiteRepositories(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "state", "test.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite error: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO channels (channel_id, category, name, enabled, fetch_limit) VALUES
+		('UC1', 'tech_en', 'One', 1, 20), ('UC2', 'news_jp', 'Two', 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := (&SQLiteChannelRepository{DB: db}).ListEnabled()
+	if err != nil {
+		t.Fatalf("ListEnabled error: %v", err)
+	}
+	if len(channels) != 1 || channels[0].ChannelID != "UC1" || channels[0].FetchLimit != 20 || !channels[0].Enabled {
+		t.Fatalf("unexpected channels %+v", channels)
+	}
+
+	notified := &SQLiteNotifiedRepository{DB: db}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := notified.Append(model.NotifiedRecord{VideoID: "VIDEO1", ChannelID: "UC1", PublishedAt: now, NotifiedAt: now}); err != nil {
+			t.Fatalf("Append error: %v", err)
+		}
+	}
+	if ok, err := notified.Has("VIDEO1"); err != nil || !ok {
+		t.Fatalf("Has(VIDEO1) = %v, %v", ok, err)
+	}
+	if ok, _ := notified.Has("VIDEO2"); ok {
+		t.Fatalf("unexpected hit for VIDEO2")
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// IsTransient reports whether a fetch error is worth retrying: 5xx and 429 answers, timeouts and
+// dropped connections. Everything else (404, quota exhaustion, bad responses) is permanent.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var feedErr *FeedHTTPError
+	if errors.As(err, &feedErr) {
+		return transientStatus(feedErr.StatusCode)
+	}
+	var apiErr *YouTubeAPIError
+	if errors.As(err, &apiErr) {
+		return transientStatus(apiErr.StatusCode)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// RetryAfterOf returns the server-requested delay carried by err, or 0.
+func RetryAfterOf(err error) time.Duration {
+	var feedErr *FeedHTTPError
+	if errors.As(err, &feedErr) {
+		return feedErr.RetryAfter
+	}
+	var apiErr *YouTubeAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
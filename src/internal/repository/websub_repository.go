@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultWebSubHubURL = "https://pubsubhubbub.appspot.com/subscribe"
+
+// WebSubHub sends subscription requests to a WebSub (PubSubHubbub) hub. The hub confirms
+// them asynchronously by calling the callback with a challenge.
+type WebSubHub interface {
+	Subscribe(req WebSubRequest) error
+	Unsubscribe(req WebSubRequest) error
+}
+
+type WebSubRequest struct {
+	Topic    string
+	Callback string
+	Secret   string // HMAC key the hub signs pushed content with; empty disables signing
+	Lease    time.Duration
+}
+
+type HTTPWebSubHub struct {
+	HubURL string // defaults to DefaultWebSubHubURL
+	Client *http.Client
+}
+
+func (h *HTTPWebSubHub) Subscribe(req WebSubRequest) error {
+	return h.send("subscribe", req)
+}
+
+func (h *HTTPWebSubHub) Unsubscribe(req WebSubRequest) error {
+	return h.send("unsubscribe", req)
+}
+
+func (h *HTTPWebSubHub) send(mode string, req WebSubRequest) error {
+	hubURL := h.HubURL
+	if hubURL == "" {
+		hubURL = DefaultWebSubHubURL
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	form := url.Values{}
+	form.Set("hub.mode", mode)
+	form.Set("hub.topic", req.Topic)
+	form.Set("hub.callback", req.Callback)
+	form.Set("hub.verify", "async")
+	if req.Secret != "" {
+		form.Set("hub.secret", req.Secret)
+	}
+	if req.Lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(req.Lease/time.Second)))
+	}
+	resp, err := client.PostForm(hubURL, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("websub hub %s status %d: %s", mode, resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// WebSubTopic is the feed URL YouTube publishes a channel's uploads under.
+func WebSubTopic(feedBaseURL, channelID string) string {
+	if feedBaseURL == "" {
+		feedBaseURL = DefaultFeedBaseURL
+	}
+	return fmt.Sprintf("%s/xml/feeds/videos.xml?channel_id=%s", strings.TrimSuffix(feedBaseURL, "/"), url.QueryEscape(channelID))
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrYouTubeQuotaExceeded means the daily quota of the key (or of every key) is used up.
+	ErrYouTubeQuotaExceeded = errors.New("youtube api quota exceeded")
+	// ErrYouTubeRateLimited is a short-term rate limit (429, rateLimitExceeded) that clears on its own.
+	ErrYouTubeRateLimited = errors.New("youtube api rate limited")
+	// ErrYouTubeInvalidKey means Google does not accept the API key at all.
+	ErrYouTubeInvalidKey = errors.New("youtube api key invalid")
+	// ErrYouTubeForbidden means the key may not call the Data API: the API is not enabled for
+	// its project, or a referrer / IP restriction blocks this host.
+	ErrYouTubeForbidden = errors.New("youtube api access forbidden")
+	// ErrYouTubePlaylistNotFound means the channel's uploads playlist does not exist.
+	ErrYouTubePlaylistNotFound = errors.New("youtube playlist not found")
+)
+
+// YouTubeAPIError is a decoded Google API error response. Err is one of the sentinels above
+// when the reason is recognised, so callers can use errors.Is.
+type YouTubeAPIError struct {
+	Err        error
+	StatusCode int
+	Reason     string // error.errors[0].reason, e.g. quotaExceeded
+	Domain     string // error.errors[0].domain, e.g. youtube.quota
+	Details    []string
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *YouTubeAPIError) Error() string {
+	if e == nil {
+		return ""
+	}
+	msg := fmt.Sprintf("youtube api status %d", e.StatusCode)
+	if e.Reason != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Reason)
+	}
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	if e.RetryAfter > 0 {
+		msg = fmt.Sprintf("%s (retry after %s)", msg, e.RetryAfter)
+	}
+	return msg
+}
+
+func (e *YouTubeAPIError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// googleErrorBody is the JSON error envelope shared by the Google APIs.
+type googleErrorBody struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Errors  []struct {
+			Reason  string `json:"reason"`
+			Domain  string `json:"domain"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Details []struct {
+			Reason string `json:"reason"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func newYouTubeAPIError(resp *http.Response) *YouTubeAPIError {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 8192))
+	ytErr := &YouTubeAPIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	var body googleErrorBody
+	if err := json.Unmarshal(raw, &body); err == nil && body.Error.Code != 0 {
+		ytErr.Message = body.Error.Message
+		if len(body.Error.Errors) > 0 {
+			ytErr.Reason = body.Error.Errors[0].Reason
+			ytErr.Domain = body.Error.Errors[0].Domain
+		}
+		for _, d := range body.Error.Details {
+			if d.Reason != "" {
+				ytErr.Details = append(ytErr.Details, d.Reason)
+			}
+		}
+	} else {
+		// Not a Google error envelope (e.g. a proxy page); keep a bounded snippet for the logs.
+		msg := strings.TrimSpace(string(raw))
+		if len(msg) > 1024 {
+			msg = msg[:1024]
+		}
+		ytErr.Message = msg
+	}
+	ytErr.Err = classifyYouTubeError(ytErr)
+	return ytErr
+}
+
+func classifyYouTubeError(e *YouTubeAPIError) error {
+	reasons := append([]string{e.Reason}, e.Details...)
+	has := func(candidates ...string) bool {
+		for _, r := range reasons {
+			for _, c := range candidates {
+				if r == c {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	switch {
+	case has("quotaExceeded", "dailyLimitExceeded"):
+		return ErrYouTubeQuotaExceeded
+	case e.StatusCode == http.StatusTooManyRequests || has("rateLimitExceeded", "userRateLimitExceeded", "RATE_LIMIT_EXCEEDED"):
+		return ErrYouTubeRateLimited
+	case has("keyInvalid", "keyExpired", "API_KEY_INVALID") ||
+		(e.StatusCode == http.StatusBadRequest && strings.Contains(e.Message, "API key")):
+		return ErrYouTubeInvalidKey
+	case has("accessNotConfigured", "SERVICE_DISABLED", "ipRefererBlocked",
+		"API_KEY_HTTP_REFERRER_BLOCKED", "API_KEY_IP_ADDRESS_BLOCKED", "API_KEY_SERVICE_BLOCKED"):
+		return ErrYouTubeForbidden
+	case has("playlistNotFound"):
+		return ErrYouTubePlaylistNotFound
+	}
+	return nil
+}
+
+// keyStatus returns why the key that produced e can no longer be used in this run, or ""
+// when the error concerns the request rather than the key.
+func (e *YouTubeAPIError) keyStatus() string {
+	switch {
+	case errors.Is(e, ErrYouTubeQuotaExceeded):
+		return "exhausted"
+	case errors.Is(e, ErrYouTubeInvalidKey):
+		return "invalid"
+	case errors.Is(e, ErrYouTubeForbidden):
+		return "forbidden"
+	}
+	return ""
+}
@@ -2,9 +2,8 @@ package repository
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"sort"
@@ -17,36 +16,95 @@ import (
 )
 
 type YouTubeRepository interface {
-	FetchUploads(channelID string, maxResults int) ([]model.VideoDTO, error)
+	FetchUploads(channelID string, maxResults int, since UploadCursor) ([]model.VideoDTO, error)
+	EnrichVideos(videos []model.VideoDTO) ([]model.VideoDTO, error)
 }
 
+const DefaultYouTubeAPIBaseURL = "https://www.googleapis.com/youtube/v3"
+
+// APIKey is one Data API key from youtube.env; Name is its variable name, used in logs and metrics.
+type APIKey struct {
+	Name  string
+	Value string
+}
+
+// YouTubeAPIRepository rotates through its keys: a key whose quota Google reports as exhausted,
+// or that Google rejects outright, is skipped for the rest of the run.
 type YouTubeAPIRepository struct {
-	APIKey        string
 	Client        *http.Client
+	BaseURL       string      // defaults to DefaultYouTubeAPIBaseURL
+	Ledger        QuotaLedger // optional; charged for every call alongside the in-process metrics
 	playlistCache map[string]string
 	cacheMu       sync.RWMutex
 	metrics       *YouTubeAPIMetrics
+	retry         RetryFunc
+
+	keyMu    sync.Mutex
+	keys     []APIKey
+	disabled map[string]string // key name -> KeyUsage.Status
 }
 
-func NewYouTubeAPIRepository(apiKey string) *YouTubeAPIRepository {
-	if apiKey == "" {
+// NewYouTubeAPIRepository returns nil when none of keys has a value.
+func NewYouTubeAPIRepository(keys []APIKey) *YouTubeAPIRepository {
+	var usable []APIKey
+	for _, k := range keys {
+		if k.Value != "" {
+			usable = append(usable, k)
+		}
+	}
+	if len(usable) == 0 {
 		return nil
 	}
 	return &YouTubeAPIRepository{
-		APIKey:        apiKey,
 		Client:        http.DefaultClient,
 		playlistCache: map[string]string{},
 		metrics:       &YouTubeAPIMetrics{},
+		keys:          usable,
+		disabled:      map[string]string{},
 	}
 }
 
-func (r *YouTubeAPIRepository) FetchUploads(channelID string, maxResults int) ([]model.VideoDTO, error) {
+// RetryFunc runs fn until it succeeds, fails permanently or the caller's retry budget is spent.
+type RetryFunc func(op, channelID string, fn func() error) error
+
+// RequestRetrier is implemented by YouTube repositories that retry each API request on their own,
+// so a transient failure on a later page repeats that page rather than the whole fetch.
+type RequestRetrier interface {
+	SetRetry(retry RetryFunc)
+}
+
+// SetRetry installs the retry policy applied to every request of FetchUploads and EnrichVideos.
+func (r *YouTubeAPIRepository) SetRetry(retry RetryFunc) {
+	r.retry = retry
+}
+
+func (r *YouTubeAPIRepository) withRetry(op, channelID string, fn func() error) error {
+	if r.retry == nil {
+		return fn()
+	}
+	return r.retry(op, channelID, fn)
+}
+
+// UploadCursor is the newest upload already known for a channel. FetchUploads stops paging
+// once it reaches that video, or an upload published before it; the zero value disables this.
+type UploadCursor struct {
+	VideoID     string
+	PublishedAt time.Time
+}
+
+func (c UploadCursor) reached(videoID string, published time.Time) bool {
+	if c.VideoID != "" && videoID == c.VideoID {
+		return true
+	}
+	return !c.PublishedAt.IsZero() && !published.IsZero() && published.Before(c.PublishedAt)
+}
+
+// FetchUploads pages through the uploads playlist, newest first, until maxResults videos are
+// collected or since is reached.
+func (r *YouTubeAPIRepository) FetchUploads(channelID string, maxResults int, since UploadCursor) ([]model.VideoDTO, error) {
 	if r == nil {
 		return nil, fmt.Errorf("youtube api repository is nil")
 	}
-	if r.APIKey == "" {
-		return nil, fmt.Errorf("youtube api key is empty")
-	}
 	playlistID := r.cachedPlaylistID(channelID)
 	if playlistID == "" {
 		return nil, fmt.Errorf("invalid channel id %s", channelID)
@@ -56,12 +114,6 @@ func (r *YouTubeAPIRepository) FetchUploads(channelID string, maxResults int) ([
 		return nil, nil
 	}
 
-	endpoint := "https://www.googleapis.com/youtube/v3/playlistItems"
-	client := r.Client
-	if client == nil {
-		client = http.DefaultClient
-	}
-
 	var (
 		out           []model.VideoDTO
 		nextPageToken string
@@ -75,75 +127,50 @@ func (r *YouTubeAPIRepository) FetchUploads(channelID string, maxResults int) ([
 		params.Set("part", "snippet,contentDetails")
 		params.Set("playlistId", playlistID)
 		params.Set("maxResults", strconv.Itoa(pageSize))
-		params.Set("key", r.APIKey)
 		if nextPageToken != "" {
 			params.Set("pageToken", nextPageToken)
 		}
 
-		requestURL := endpoint + "?" + params.Encode()
-
-		resp, err := client.Get(requestURL)
+		var payload youtubePlaylistItemsResponse
+		err := r.withRetry("youtube api fetch", channelID, func() error {
+			payload = youtubePlaylistItemsResponse{}
+			return r.getJSON(endpointPlaylistItems, "playlistItems", params, &payload)
+		})
 		if err != nil {
 			return nil, err
 		}
-		func() {
-			defer resp.Body.Close()
-
-			if resp.StatusCode >= 300 {
-				snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-				ytErr := &YouTubeAPIError{
-					StatusCode: resp.StatusCode,
-					Message:    strings.TrimSpace(string(snippet)),
-				}
-				if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 {
-					ytErr.RetryAfter = retryAfter
-				}
-				if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden {
-					ytErr.Err = ErrYouTubeRateLimited
-				}
-				err = ytErr
-				return
-			}
 
-			var payload youtubePlaylistItemsResponse
-			if decodeErr := json.NewDecoder(resp.Body).Decode(&payload); decodeErr != nil {
-				err = decodeErr
-				return
+		for _, item := range payload.Items {
+			videoID := item.ContentDetails.VideoID
+			if videoID == "" {
+				videoID = item.Snippet.ResourceID.VideoID
 			}
-
-			for _, item := range payload.Items {
-				videoID := item.ContentDetails.VideoID
-				if videoID == "" {
-					videoID = item.Snippet.ResourceID.VideoID
-				}
-				if videoID == "" {
-					continue
-				}
-				published := firstTime(item.ContentDetails.VideoPublishedAt, item.Snippet.PublishedAt)
-				out = append(out, model.VideoDTO{
-					VideoID:     videoID,
-					Title:       item.Snippet.Title,
-					Link:        fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID),
-					ChannelID:   channelID,
-					ChannelName: item.Snippet.ChannelTitle,
-					PublishedAt: published,
-				})
-				if len(out) >= totalRequested {
-					break
-				}
+			if videoID == "" {
+				continue
 			}
-
-			nextPageToken = payload.NextPageToken
-			if nextPageToken == "" || len(payload.Items) == 0 {
+			published := firstTime(item.ContentDetails.VideoPublishedAt, item.Snippet.PublishedAt)
+			if since.reached(videoID, published) {
 				totalRequested = len(out)
+				break
+			}
+			out = append(out, model.VideoDTO{
+				VideoID:     videoID,
+				Title:       item.Snippet.Title,
+				Description: item.Snippet.Description,
+				Link:        fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID),
+				ChannelID:   channelID,
+				ChannelName: item.Snippet.ChannelTitle,
+				PublishedAt: published,
+			})
+			if len(out) >= totalRequested {
+				break
 			}
-		}()
-
-		if err != nil {
-			return nil, err
 		}
 
-		r.metrics.IncrementRequests()
+		nextPageToken = payload.NextPageToken
+		if nextPageToken == "" || len(payload.Items) == 0 {
+			totalRequested = len(out)
+		}
 
 		if len(out) >= totalRequested {
 			break
@@ -164,6 +191,173 @@ func (r *YouTubeAPIRepository) FetchUploads(channelID string, maxResults int) ([
 	return out, nil
 }
 
+// EnrichVideos looks the videos up via videos.list in batches of up to 50 IDs, fills in
+// duration, statistics, tags, language and live details, and classifies them as regular
+// uploads, Shorts, live streams, upcoming premieres or finished lives. Videos the API does
+// not return keep whatever the feed already told us.
+func (r *YouTubeAPIRepository) EnrichVideos(videos []model.VideoDTO) ([]model.VideoDTO, error) {
+	if r == nil || len(videos) == 0 {
+		return videos, nil
+	}
+
+	out := make([]model.VideoDTO, len(videos))
+	copy(out, videos)
+
+	for start := 0; start < len(out); start += videosListBatchSize {
+		end := start + videosListBatchSize
+		if end > len(out) {
+			end = len(out)
+		}
+		ids := make([]string, 0, end-start)
+		for _, v := range out[start:end] {
+			ids = append(ids, v.VideoID)
+		}
+
+		params := url.Values{}
+		params.Set("part", "snippet,contentDetails,statistics,liveStreamingDetails")
+		params.Set("id", strings.Join(ids, ","))
+
+		var payload youtubeVideosResponse
+		err := r.withRetry("youtube api enrich", out[start].ChannelID, func() error {
+			payload = youtubeVideosResponse{}
+			return r.getJSON(endpointVideos, "videos", params, &payload)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		byID := make(map[string]youtubeVideoItem, len(payload.Items))
+		for _, item := range payload.Items {
+			byID[item.ID] = item
+		}
+		for i := start; i < end; i++ {
+			item, ok := byID[out[i].VideoID]
+			if !ok {
+				continue
+			}
+			applyVideoDetails(&out[i], item)
+		}
+	}
+	return out, nil
+}
+
+func applyVideoDetails(v *model.VideoDTO, item youtubeVideoItem) {
+	v.Enriched = true
+	v.Kind = classifyVideo(item, v.Kind)
+	if d, err := parseISODuration(item.ContentDetails.Duration); err == nil {
+		v.Duration = d
+	}
+	v.ViewCount = parseCount(item.Statistics.ViewCount)
+	v.LikeCount = parseCount(item.Statistics.LikeCount)
+	v.Tags = item.Snippet.Tags
+	v.DefaultAudioLanguage = item.Snippet.DefaultAudioLanguage
+	v.DefaultLanguage = item.Snippet.DefaultLanguage
+	v.LiveBroadcastContent = item.Snippet.LiveBroadcastContent
+	if item.LiveStreamingDetails != nil && item.LiveStreamingDetails.ScheduledStartTime != "" {
+		if t, err := time.Parse(time.RFC3339, item.LiveStreamingDetails.ScheduledStartTime); err == nil {
+			v.ScheduledStartTime = t
+		}
+	}
+}
+
+// parseCount converts the string-encoded counters of the statistics part; hidden counters stay 0.
+func parseCount(raw string) int64 {
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// classifyVideo derives the kind from videos.list data. feedKind is what the RSS link
+// told us; it is authoritative for Shorts but says nothing about live content. videos.list
+// cannot tell a Short from a short regular upload, so videos without a feed hint (fetched via
+// playlistItems) count as regular rather than being filtered out on a guess.
+func classifyVideo(item youtubeVideoItem, feedKind model.VideoKind) model.VideoKind {
+	duration, _ := parseISODuration(item.ContentDetails.Duration)
+	switch item.Snippet.LiveBroadcastContent {
+	case "live":
+		return model.VideoKindLive
+	case "upcoming":
+		// Premieres already have the uploaded file attached; scheduled streams report P0D.
+		if duration > 0 {
+			return model.VideoKindPremiere
+		}
+		return model.VideoKindLive
+	}
+	if item.LiveStreamingDetails != nil && item.LiveStreamingDetails.ActualEndTime != "" {
+		return model.VideoKindLiveArchive
+	}
+	if feedKind != model.VideoKindUnknown {
+		return feedKind
+	}
+	return model.VideoKindRegular
+}
+
+// ResolveChannelID looks up the UC... ID behind a handle, custom URL or legacy username via channels.list.
+// Custom URLs have no direct lookup, so they are tried as a handle first and then as a username.
+func (r *YouTubeAPIRepository) ResolveChannelID(ref ChannelRef) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("youtube api repository is nil")
+	}
+	var lookups []string
+	switch ref.Kind {
+	case ChannelRefID:
+		return ref.Value, nil
+	case ChannelRefHandle:
+		lookups = []string{"forHandle"}
+	case ChannelRefUsername:
+		lookups = []string{"forUsername"}
+	case ChannelRefCustom:
+		lookups = []string{"forHandle", "forUsername"}
+	default:
+		return "", fmt.Errorf("unsupported channel reference %s", ref)
+	}
+
+	for _, param := range lookups {
+		id, err := r.lookupChannel(param, ref.Value)
+		if err != nil {
+			return "", err
+		}
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrChannelNotFound, ref)
+}
+
+func (r *YouTubeAPIRepository) lookupChannel(param, value string) (string, error) {
+	params := url.Values{}
+	params.Set("part", "id")
+	params.Set(param, value)
+
+	resp, err := r.call(endpointChannels, "channels", params)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	if len(payload.Items) == 0 {
+		return "", nil
+	}
+	return payload.Items[0].ID, nil
+}
+
+func (r *YouTubeAPIRepository) endpoint(resource string) string {
+	base := r.BaseURL
+	if base == "" {
+		base = DefaultYouTubeAPIBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/" + resource
+}
+
 func (r *YouTubeAPIRepository) cachedPlaylistID(channelID string) string {
 	r.cacheMu.RLock()
 	cached := r.playlistCache[channelID]
@@ -181,6 +375,109 @@ func (r *YouTubeAPIRepository) cachedPlaylistID(channelID string) string {
 	return computed
 }
 
+// call issues a GET against resource with the current key and returns a 2xx response. When
+// Google reports the key's quota as used up or rejects the key it moves on to the next key;
+// once no key is left the last error is returned.
+func (r *YouTubeAPIRepository) call(endpoint, resource string, params url.Values) (*http.Response, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var lastErr error
+	for {
+		key, ok := r.currentKey()
+		if !ok {
+			if lastErr == nil {
+				lastErr = fmt.Errorf("%w: every youtube api key is exhausted", ErrYouTubeQuotaExceeded)
+			}
+			return nil, lastErr
+		}
+		params.Set("key", key.Value)
+		resp, err := client.Get(r.endpoint(resource) + "?" + params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		r.record(endpoint, key.Name)
+		if resp.StatusCode < 300 {
+			return resp, nil
+		}
+		apiErr := newYouTubeAPIError(resp)
+		resp.Body.Close()
+		status := apiErr.keyStatus()
+		if status == "" {
+			return nil, apiErr
+		}
+		log.Printf("youtube api key %s is %s (%s); rotating to the next key", key.Name, status, apiErr.Reason)
+		r.disableKey(key.Name, status)
+		lastErr = apiErr
+	}
+}
+
+// getJSON calls resource and decodes the response body into v.
+func (r *YouTubeAPIRepository) getJSON(endpoint, resource string, params url.Values, v any) error {
+	resp, err := r.call(endpoint, resource, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (r *YouTubeAPIRepository) currentKey() (APIKey, bool) {
+	r.keyMu.Lock()
+	defer r.keyMu.Unlock()
+	for _, k := range r.keys {
+		if r.disabled[k.Name] == "" {
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+func (r *YouTubeAPIRepository) disableKey(name, status string) {
+	r.keyMu.Lock()
+	defer r.keyMu.Unlock()
+	r.disabled[name] = status
+}
+
+// KeyUsage summarises one key's calls in this run.
+type KeyUsage struct {
+	Name       string
+	Requests   int
+	QuotaUnits int
+	Status     string // empty while usable, otherwise exhausted, invalid or forbidden
+}
+
+// KeyUsages lists every configured key in rotation order.
+func (r *YouTubeAPIRepository) KeyUsages() []KeyUsage {
+	if r == nil {
+		return nil
+	}
+	snapshot := r.metrics.Snapshot()
+	r.keyMu.Lock()
+	defer r.keyMu.Unlock()
+	out := make([]KeyUsage, 0, len(r.keys))
+	for _, k := range r.keys {
+		usage := snapshot.ByKey[k.Name]
+		usage.Name = k.Name
+		usage.Status = r.disabled[k.Name]
+		out = append(out, usage)
+	}
+	return out
+}
+
+// record charges one call to endpoint made with key. Google bills requests that fail with an
+// error response as well, so it is called as soon as a response arrives.
+func (r *YouTubeAPIRepository) record(endpoint, key string) {
+	r.metrics.Record(endpoint, key)
+	if r.Ledger == nil {
+		return
+	}
+	if err := r.Ledger.Charge(endpoint, quotaCost(endpoint)); err != nil {
+		log.Printf("failed to update youtube quota ledger: %v", err)
+	}
+}
+
 // Metrics returns a snapshot of the quota usage observed by the repository.
 func (r *YouTubeAPIRepository) Metrics() YouTubeAPIMetricsSnapshot {
 	if r == nil || r.metrics == nil {
@@ -189,62 +486,81 @@ func (r *YouTubeAPIRepository) Metrics() YouTubeAPIMetricsSnapshot {
 	return r.metrics.Snapshot()
 }
 
+const (
+	endpointPlaylistItems = "playlistItems.list"
+	endpointVideos        = "videos.list"
+	endpointChannels      = "channels.list"
+)
+
+// quotaCosts lists the Data API quota units charged per call.
+var quotaCosts = map[string]int{
+	endpointPlaylistItems: 1,
+	endpointVideos:        1,
+	endpointChannels:      1,
+}
+
+func quotaCost(endpoint string) int {
+	if cost, ok := quotaCosts[endpoint]; ok {
+		return cost
+	}
+	return 1
+}
+
 type YouTubeAPIMetrics struct {
 	mu             sync.Mutex
 	requestCount   int
 	quotaUnitCount int
+	byEndpoint     map[string]int
+	byKey          map[string]KeyUsage
 }
 
-func (m *YouTubeAPIMetrics) IncrementRequests() {
+// Record counts one call to endpoint made with the named key and charges its quota cost.
+func (m *YouTubeAPIMetrics) Record(endpoint, key string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	cost := quotaCost(endpoint)
 	m.requestCount++
-	m.quotaUnitCount++ // playlistItems.list = 1 unit per call
+	m.quotaUnitCount += cost
+	if m.byEndpoint == nil {
+		m.byEndpoint = map[string]int{}
+	}
+	m.byEndpoint[endpoint] += cost
+	if m.byKey == nil {
+		m.byKey = map[string]KeyUsage{}
+	}
+	usage := m.byKey[key]
+	usage.Requests++
+	usage.QuotaUnits += cost
+	m.byKey[key] = usage
 }
 
 func (m *YouTubeAPIMetrics) Snapshot() YouTubeAPIMetricsSnapshot {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	byEndpoint := make(map[string]int, len(m.byEndpoint))
+	for k, v := range m.byEndpoint {
+		byEndpoint[k] = v
+	}
+	byKey := make(map[string]KeyUsage, len(m.byKey))
+	for k, v := range m.byKey {
+		byKey[k] = v
+	}
 	return YouTubeAPIMetricsSnapshot{
 		Requests:   m.requestCount,
 		QuotaUnits: m.quotaUnitCount,
+		ByEndpoint: byEndpoint,
+		ByKey:      byKey,
 	}
 }
 
 type YouTubeAPIMetricsSnapshot struct {
 	Requests   int
 	QuotaUnits int
+	ByEndpoint map[string]int      // quota units per endpoint
+	ByKey      map[string]KeyUsage // calls and units per key name
 }
 
-var ErrYouTubeRateLimited = errors.New("youtube api rate limited")
-
-type YouTubeAPIError struct {
-	Err        error
-	StatusCode int
-	RetryAfter time.Duration
-	Message    string
-}
-
-func (e *YouTubeAPIError) Error() string {
-	if e == nil {
-		return ""
-	}
-	msg := fmt.Sprintf("youtube api status %d", e.StatusCode)
-	if e.Message != "" {
-		msg = fmt.Sprintf("%s: %s", msg, e.Message)
-	}
-	if e.RetryAfter > 0 {
-		msg = fmt.Sprintf("%s (retry after %s)", msg, e.RetryAfter)
-	}
-	return msg
-}
-
-func (e *YouTubeAPIError) Unwrap() error {
-	if e == nil {
-		return nil
-	}
-	return e.Err
-}
+const videosListBatchSize = 50
 
 func parseRetryAfter(value string) time.Duration {
 	if value == "" {
@@ -263,6 +579,7 @@ type youtubePlaylistItemsResponse struct {
 	Items []struct {
 		Snippet struct {
 			Title        string `json:"title"`
+			Description  string `json:"description"`
 			ChannelTitle string `json:"channelTitle"`
 			PublishedAt  string `json:"publishedAt"`
 			ResourceID   struct {
@@ -277,6 +594,86 @@ type youtubePlaylistItemsResponse struct {
 	NextPageToken string `json:"nextPageToken"`
 }
 
+type youtubeVideosResponse struct {
+	Items []youtubeVideoItem `json:"items"`
+}
+
+type youtubeVideoItem struct {
+	ID      string `json:"id"`
+	Snippet struct {
+		LiveBroadcastContent string   `json:"liveBroadcastContent"`
+		Tags                 []string `json:"tags"`
+		DefaultAudioLanguage string   `json:"defaultAudioLanguage"`
+		DefaultLanguage      string   `json:"defaultLanguage"`
+	} `json:"snippet"`
+	ContentDetails struct {
+		Duration string `json:"duration"`
+	} `json:"contentDetails"`
+	Statistics struct {
+		ViewCount string `json:"viewCount"`
+		LikeCount string `json:"likeCount"`
+	} `json:"statistics"`
+	LiveStreamingDetails *struct {
+		ActualStartTime    string `json:"actualStartTime"`
+		ActualEndTime      string `json:"actualEndTime"`
+		ScheduledStartTime string `json:"scheduledStartTime"`
+	} `json:"liveStreamingDetails"`
+}
+
+// parseISODuration parses the ISO-8601 durations used by contentDetails.duration (e.g. PT1H2M3S, P1DT2H).
+func parseISODuration(raw string) (time.Duration, error) {
+	s := strings.TrimSpace(raw)
+	if !strings.HasPrefix(s, "P") || len(s) < 2 {
+		return 0, fmt.Errorf("invalid iso8601 duration %q", raw)
+	}
+	s = s[1:]
+	var (
+		total  time.Duration
+		inTime bool
+		num    string
+	)
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9' || c == '.':
+			num += string(c)
+		case c == 'T':
+			if inTime || num != "" {
+				return 0, fmt.Errorf("invalid iso8601 duration %q", raw)
+			}
+			inTime = true
+		default:
+			if num == "" {
+				return 0, fmt.Errorf("invalid iso8601 duration %q", raw)
+			}
+			v, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid iso8601 duration %q: %w", raw, err)
+			}
+			var unit time.Duration
+			switch {
+			case !inTime && c == 'W':
+				unit = 7 * 24 * time.Hour
+			case !inTime && c == 'D':
+				unit = 24 * time.Hour
+			case inTime && c == 'H':
+				unit = time.Hour
+			case inTime && c == 'M':
+				unit = time.Minute
+			case inTime && c == 'S':
+				unit = time.Second
+			default:
+				return 0, fmt.Errorf("invalid iso8601 duration %q", raw)
+			}
+			total += time.Duration(v * float64(unit))
+			num = ""
+		}
+	}
+	if num != "" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid iso8601 duration %q", raw)
+	}
+	return total, nil
+}
+
 func uploadsPlaylistID(channelID string) string {
 	trimmed := strings.TrimSpace(channelID)
 	if trimmed == "" {
@@ -285,7 +682,8 @@ func uploadsPlaylistID(channelID string) string {
 	if strings.HasPrefix(trimmed, "UC") && len(trimmed) > 2 {
 		return "UU" + trimmed[2:]
 	}
-	return trimmed
+	// Handles and custom URLs must be resolved to a channel ID first; guessing here only yields 404s.
+	return ""
 }
 
 func clamp(v, min, max int) int {
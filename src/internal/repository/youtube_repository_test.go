@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+func TestParseISODuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"PT1H2M3S": time.Hour + 2*time.Minute + 3*time.Second,
+		"PT45S":    45 * time.Second,
+		"P1DT2H":   26 * time.Hour,
+		"P0D":      0,
+	}
+	for raw, want := range cases {
+		got, err := parseISODuration(raw)
+		if err != nil {
+			t.Fatalf("parseISODuration(%q) error: %v", raw, err)
+		}
+		if got != want {
+			t.Fatalf("parseISODuration(%q) = %s, want %s", raw, got, want)
+		}
+	}
+	for _, raw := range []string{"", "1H", "PT", "PTH", "P1H"} {
+		if _, err := parseISODuration(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestClassifyVideo(t *testing.T) {
+	cases := []struct {
+		name     string
+		payload  string
+		feedKind model.VideoKind
+		want     model.VideoKind
+	}{
+		{"regular", `{"snippet":{"liveBroadcastContent":"none"},"contentDetails":{"duration":"PT12M"}}`, model.VideoKindUnknown, model.VideoKindRegular},
+		{"short upload without feed hint", `{"snippet":{"liveBroadcastContent":"none"},"contentDetails":{"duration":"PT40S"}}`, model.VideoKindUnknown, model.VideoKindRegular},
+		{"feed says short", `{"snippet":{"liveBroadcastContent":"none"},"contentDetails":{"duration":"PT40S"}}`, model.VideoKindShort, model.VideoKindShort},
+		{"feed says regular", `{"snippet":{"liveBroadcastContent":"none"},"contentDetails":{"duration":"PT40S"}}`, model.VideoKindRegular, model.VideoKindRegular},
+		{"live now", `{"snippet":{"liveBroadcastContent":"live"},"contentDetails":{"duration":"P0D"},"liveStreamingDetails":{"actualStartTime":"2024-01-01T00:00:00Z"}}`, model.VideoKindRegular, model.VideoKindLive},
+		{"scheduled stream", `{"snippet":{"liveBroadcastContent":"upcoming"},"contentDetails":{"duration":"P0D"},"liveStreamingDetails":{"scheduledStartTime":"2024-01-01T00:00:00Z"}}`, model.VideoKindRegular, model.VideoKindLive},
+		{"premiere", `{"snippet":{"liveBroadcastContent":"upcoming"},"contentDetails":{"duration":"PT20M"},"liveStreamingDetails":{"scheduledStartTime":"2024-01-01T00:00:00Z"}}`, model.VideoKindRegular, model.VideoKindPremiere},
+		{"finished live", `{"snippet":{"liveBroadcastContent":"none"},"contentDetails":{"duration":"PT2H"},"liveStreamingDetails":{"actualStartTime":"2024-01-01T00:00:00Z","actualEndTime":"2024-01-01T02:00:00Z"}}`, model.VideoKindRegular, model.VideoKindLiveArchive},
+	}
+	for _, tc := range cases {
+		var item youtubeVideoItem
+		if err := json.Unmarshal([]byte(tc.payload), &item); err != nil {
+			t.Fatalf("%s: unmarshal: %v", tc.name, err)
+		}
+		if got := classifyVideo(item, tc.feedKind); got != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAPIKeyRotationOnQuotaExceeded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") == "first" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"quota","errors":[{"reason":"quotaExceeded","domain":"youtube.quota"}]}}`))
+			return
+		}
+		w.Write([]byte(`{"items":[{"id":"UCxxxxxxxxxxxxxxxxxxxxxx"}]}`))
+	}))
+	defer srv.Close()
+
+	repo := NewYouTubeAPIRepository([]APIKey{{Name: "KEY_A", Value: "first"}, {Name: "KEY_B", Value: "second"}})
+	repo.Client = srv.Client()
+	repo.BaseURL = srv.URL
+	for i := 0; i < 2; i++ {
+		id, err := repo.ResolveChannelID(ChannelRef{Kind: ChannelRefHandle, Value: "@example"})
+		if err != nil || id != "UCxxxxxxxxxxxxxxxxxxxxxx" {
+			t.Fatalf("ResolveChannelID = %q, %v", id, err)
+		}
+	}
+
+	usages := repo.KeyUsages()
+	if len(usages) != 2 || usages[0].Status != "exhausted" || usages[0].Requests != 1 || usages[1].Status != "" || usages[1].Requests != 2 {
+		t.Fatalf("unexpected key usages %+v", usages)
+	}
+}
+
+func TestYouTubeAPIErrorClassification(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{403, `{"error":{"code":403,"message":"quota","errors":[{"reason":"quotaExceeded","domain":"youtube.quota"}]}}`, ErrYouTubeQuotaExceeded},
+		{400, `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","errors":[{"reason":"badRequest","domain":"global"}],"details":[{"reason":"API_KEY_INVALID"}]}}`, ErrYouTubeInvalidKey},
+		{403, `{"error":{"code":403,"message":"not enabled","errors":[{"reason":"accessNotConfigured","domain":"usageLimits"}]}}`, ErrYouTubeForbidden},
+		{404, `{"error":{"code":404,"message":"missing","errors":[{"reason":"playlistNotFound","domain":"youtube.playlistItem"}]}}`, ErrYouTubePlaylistNotFound},
+		{429, `too many requests`, ErrYouTubeRateLimited},
+		{403, `{"error":{"code":403,"message":"private","errors":[{"reason":"playlistItemsNotAccessible","domain":"youtube.playlistItem"}]}}`, nil},
+	}
+	for _, tc := range cases {
+		err := newYouTubeAPIError(&http.Response{StatusCode: tc.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tc.body))})
+		if tc.want == nil {
+			if err.Err != nil {
+				t.Errorf("status %d: unexpected sentinel %v", tc.status, err.Err)
+			}
+			continue
+		}
+		if !errors.Is(err, tc.want) {
+			t.Errorf("status %d: got %v (%v), want %v", tc.status, err.Err, err, tc.want)
+		}
+	}
+}
+
+func TestFetchUploadsStopsAtKnownVideo(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"nextPageToken":"p2","items":[{"contentDetails":{"videoId":"NEW2","videoPublishedAt":"2024-01-03T00:00:00Z"}},{"contentDetails":{"videoId":"NEW1","videoPublishedAt":"2024-01-02T00:00:00Z"}}]}`,
+		"p2": `{"nextPageToken":"p3","items":[{"contentDetails":{"videoId":"KNOWN","videoPublishedAt":"2024-01-01T00:00:00Z"}},{"contentDetails":{"videoId":"OLD","videoPublishedAt":"2023-12-31T00:00:00Z"}}]}`,
+		"p3": `{"items":[{"contentDetails":{"videoId":"OLDER","videoPublishedAt":"2023-12-30T00:00:00Z"}}]}`,
+	}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(pages[r.URL.Query().Get("pageToken")]))
+	}))
+	defer srv.Close()
+
+	repo := NewYouTubeAPIRepository([]APIKey{{Name: "KEY", Value: "k"}})
+	repo.Client = srv.Client()
+	repo.BaseURL = srv.URL
+	const channelID = "UCxxxxxxxxxxxxxxxxxxxxxx"
+
+	videos, err := repo.FetchUploads(channelID, 50, UploadCursor{VideoID: "KNOWN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 2 || videos[0].VideoID != "NEW2" || videos[1].VideoID != "NEW1" || requests != 2 {
+		t.Fatalf("got %+v after %d requests, want NEW2, NEW1 after 2", videos, requests)
+	}
+
+	requests = 0
+	since := UploadCursor{VideoID: "GONE", PublishedAt: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)}
+	if videos, err = repo.FetchUploads(channelID, 50, since); err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "NEW2" || requests != 1 {
+		t.Fatalf("got %+v after %d requests, want NEW2 after 1", videos, requests)
+	}
+}
+
+func TestFetchUploadsRetriesOnlyTheFailedPage(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"nextPageToken":"p2","items":[{"contentDetails":{"videoId":"V3","videoPublishedAt":"2024-01-03T00:00:00Z"}}]}`,
+		"p2": `{"items":[{"contentDetails":{"videoId":"V2","videoPublishedAt":"2024-01-02T00:00:00Z"}}]}`,
+	}
+	requests := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("pageToken")
+		requests[token]++
+		if token == "p2" && requests[token] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(pages[token]))
+	}))
+	defer srv.Close()
+
+	repo := NewYouTubeAPIRepository([]APIKey{{Name: "KEY", Value: "k"}})
+	repo.Client = srv.Client()
+	repo.BaseURL = srv.URL
+	retries := 0
+	repo.SetRetry(func(op, channelID string, fn func() error) error {
+		for {
+			err := fn()
+			if err == nil || !IsTransient(err) || retries >= 3 {
+				return err
+			}
+			retries++
+		}
+	})
+
+	videos, err := repo.FetchUploads("UCxxxxxxxxxxxxxxxxxxxxxx", 50, UploadCursor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 2 || retries != 1 {
+		t.Fatalf("got %+v after %d retries, want 2 videos after 1", videos, retries)
+	}
+	if requests[""] != 1 || requests["p2"] != 2 {
+		t.Fatalf("requests per page = %v, want the first page once and the failed page twice", requests)
+	}
+}
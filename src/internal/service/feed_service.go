@@ -2,17 +2,34 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/hellomyzn/yt-notifier/internal/expr"
 	"github.com/hellomyzn/yt-notifier/internal/model"
 	"github.com/hellomyzn/yt-notifier/internal/repository"
 )
 
 const rssMaxWindow = 15
 
+// deepFetchMinResults is one full playlistItems page, which costs the same unit as a smaller one.
+const deepFetchMinResults = 50
+
 type FeedService interface {
 	ListNewVideos(ch model.ChannelDTO) ([]model.VideoDTO, error)
+	// FilterNew runs videos obtained elsewhere (e.g. pushed by a WebSub hub) through the same
+	// history check, enrichment and filters as ListNewVideos.
+	FilterNew(ch model.ChannelDTO, videos []model.VideoDTO) ([]model.VideoDTO, error)
+	// Seed records the channel's current videos as seen without notifying them. With keepNewest
+	// the newest one is returned (after the usual filters) so the caller can notify it.
+	Seed(ch model.ChannelDTO, keepNewest bool) ([]model.VideoDTO, error)
+	// Commit persists per-run state such as feed validators. Call it once every video returned
+	// by ListNewVideos has been handed to the notify service.
+	Commit() error
 	Stats() FeedStats
 }
 
@@ -22,87 +39,670 @@ type FeedStats struct {
 	APIFallbacks       int
 	RSSFallbacks       int
 	SaturationTriggers int
+	Filtered           int
+	NotModified        int // conditional RSS requests answered with 304
+	Retries            int // retried transient fetch errors
+	RetryExhausted     int // fetches that still failed after their retries
+	QuotaSkips         int // API calls skipped because the daily quota budget was used up
+	Seeded             int // videos recorded without notification on a channel's first run
+	TooOld             int // videos skipped for being older than max_age
+	RuleExcluded       int // videos dropped by include/exclude rules
+	ExprFiltered       int // videos rejected by a filter expression
+	DurationFiltered   int // videos outside min_duration / max_duration
+	DurationHeld       int // videos left unseen until their duration is known
+	ExprHeld           int // videos left unseen until a filter expression can judge them
+	Routed             int // videos sent to another category than their channel's
+	LanguageRouted     int // videos sent to the _jp or _en variant of their category
+}
+
+// FirstRunMode decides what happens to the existing videos of a channel without history.
+type FirstRunMode string
+
+const (
+	FirstRunSeed      FirstRunMode = "seed"   // record them all as seen (default)
+	FirstRunNewest    FirstRunMode = "newest" // notify only the newest one
+	FirstRunNotifyAll FirstRunMode = "all"    // notify every video in the window
+)
+
+// UnknownDurationMode decides what duration limits, and filter expressions reading enrichment
+// fields, do with videos that were not enriched via the API, whose length is unknown.
+type UnknownDurationMode string
+
+const (
+	UnknownDurationPass UnknownDurationMode = "pass" // notify them (default)
+	UnknownDurationHold UnknownDurationMode = "hold" // leave them unseen for a run that can enrich them
+)
+
+// FeedOptions are the filter settings from app.yaml.
+type FeedOptions struct {
+	IncludeLive      bool
+	IncludePremieres bool
+	IncludeShorts    bool
+	Retry            RetryPolicy
+	Quota            QuotaBudget
+	FirstRun         FirstRunMode
+	MaxAge           MaxAge             // zero means no limit
+	CategoryMaxAge   map[string]MaxAge  // by lowercased category, overrides MaxAge
+	Location         *time.Location     // for day-based max_age windows
+	CategoryRules    map[string]RuleSet // by lowercased category; channels.csv rules are added on top
+	// CategoryFilters (by lowercased category) and ChannelFilters (by channel ID) are filter
+	// expressions; a video must pass both that apply.
+	CategoryFilters map[string]*expr.Program
+	ChannelFilters  map[string]*expr.Program
+	// MinDuration and MaxDuration (zero for no limit) are overridden per lowercased category.
+	MinDuration         time.Duration
+	MaxDuration         time.Duration
+	CategoryMinDuration map[string]time.Duration
+	CategoryMaxDuration map[string]time.Duration
+	UnknownDuration     UnknownDurationMode
+	// CategoryRoutes sends videos of a lowercased channel category ("*" for any) to other
+	// categories; channels.csv routes are checked first.
+	CategoryRoutes map[string][]Route
+	// LanguageRouting picks <category>_jp or <category>_en per video once it passed the filters
+	// of its base category.
+	LanguageRouting LanguageRouting
+}
+
+// QuotaBudget stops API use for the rest of the quota day once Ledger shows that DailyQuota
+// minus Reserve units are spent. A nil Ledger or zero DailyQuota disables the check.
+type QuotaBudget struct {
+	Ledger     repository.QuotaLedger
+	DailyQuota int
+	Reserve    int
 }
 
 type feedService struct {
 	rssRepo          repository.FeedRepository
 	ytRepo           repository.YouTubeRepository
 	notifiedRepo     repository.NotifiedRepository
+	validatorRepo    repository.FeedValidatorRepository
 	includeLive      bool
 	includePremieres bool
 	includeShorts    bool
+	retry            RetryPolicy
+	quota            QuotaBudget
+	firstRun         FirstRunMode
+	maxAge           MaxAge
+	categoryMaxAge   map[string]MaxAge
+	location         *time.Location
+	categoryRules    map[string]RuleSet
+	categoryFilters  map[string]*expr.Program
+	channelFilters   map[string]*expr.Program
+	minDuration      time.Duration
+	maxDuration      time.Duration
+	categoryMinDur   map[string]time.Duration
+	categoryMaxDur   map[string]time.Duration
+	unknownDuration  UnknownDurationMode
+	categoryRoutes   map[string][]Route
+	languageRouting  LanguageRouting
+	sleep            func(time.Duration)
+	jitter           func(time.Duration) time.Duration
+	ytRetries        bool // ytRepo retries each request itself
 
-	mu    sync.Mutex
-	stats FeedStats
+	mu         sync.Mutex
+	stats      FeedStats
+	validators map[string]model.FeedValidators // staged until Commit
+	quotaSpent bool                            // logged once when the budget runs out
+	apiOff     bool                            // set when the API cannot work for the rest of the run
 }
 
+// NewFeedService wires the feed sources. validators may be nil to always download feeds in full.
 func NewFeedService(rss repository.FeedRepository, yt repository.YouTubeRepository, notified repository.NotifiedRepository,
-	includeLive, includePremieres, includeShorts bool) FeedService {
-	return &feedService{rssRepo: rss, ytRepo: yt, notifiedRepo: notified,
-		includeLive: includeLive, includePremieres: includePremieres, includeShorts: includeShorts}
+	validators repository.FeedValidatorRepository, opts FeedOptions) FeedService {
+	s := &feedService{rssRepo: rss, ytRepo: yt, notifiedRepo: notified, validatorRepo: validators,
+		includeLive: opts.IncludeLive, includePremieres: opts.IncludePremieres, includeShorts: opts.IncludeShorts,
+		retry: opts.Retry, quota: opts.Quota, firstRun: opts.FirstRun,
+		maxAge: opts.MaxAge, categoryMaxAge: opts.CategoryMaxAge, location: opts.Location,
+		categoryRules: opts.CategoryRules, categoryFilters: opts.CategoryFilters, channelFilters: opts.ChannelFilters,
+		minDuration: opts.MinDuration, maxDuration: opts.MaxDuration, categoryMinDur: opts.CategoryMinDuration,
+		categoryMaxDur: opts.CategoryMaxDuration, unknownDuration: opts.UnknownDuration,
+		categoryRoutes: opts.CategoryRoutes, languageRouting: opts.LanguageRouting, sleep: time.Sleep, jitter: randomJitter,
+		validators: map[string]model.FeedValidators{}}
+	if r, ok := yt.(repository.RequestRetrier); ok {
+		r.SetRetry(s.withRetry)
+		s.ytRetries = true
+	}
+	return s
 }
 
 func (s *feedService) ListNewVideos(ch model.ChannelDTO) ([]model.VideoDTO, error) {
-	var (
-		videos []model.VideoDTO
-		err    error
-	)
-	useYouTube := ch.FetchLimit >= rssMaxWindow && s.ytRepo != nil
+	videos, validators, notModified, fromAPI, err := s.fetch(ch)
+	if err != nil {
+		return nil, err
+	}
+	if notModified {
+		return nil, nil
+	}
+
+	first, err := s.isFirstRun(ch)
+	if err != nil {
+		return nil, err
+	}
+	if first {
+		log.Printf("channel=%s has no history yet; seeding its current videos", ch.ChannelID)
+		if videos, err = s.seed(ch, videos, s.firstRun == FirstRunNewest); err != nil {
+			return nil, err
+		}
+	} else if !fromAPI && s.ytRepo != nil && len(videos) >= rssMaxWindow {
+		saturated, err := s.rssSaturated(videos)
+		if err != nil {
+			return nil, err
+		}
+		if saturated && s.apiAllowed() {
+			videos = s.deepFetch(ch, videos)
+		}
+	}
+
+	out, held, err := s.filterNew(ch, videos)
+	if err != nil {
+		return nil, err
+	}
+	if held == 0 {
+		// A held video must be fetched again even if the feed does not change.
+		s.stageValidators(ch.ChannelID, validators)
+	}
+	return out, nil
+}
+
+// rssSaturated reports whether every entry of a full RSS window is unseen, in which case older
+// uploads may have dropped out of the feed.
+func (s *feedService) rssSaturated(videos []model.VideoDTO) (bool, error) {
+	for _, v := range videos {
+		seen, err := s.notifiedRepo.Has(v.VideoID)
+		if err != nil {
+			return false, err
+		}
+		if seen {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// deepFetch pages the uploads playlist back to the newest known video, keeping the RSS videos
+// when the API fails.
+func (s *feedService) deepFetch(ch model.ChannelDTO, videos []model.VideoDTO) []model.VideoDTO {
+	log.Printf("rss feed saturated for channel=%s; attempting youtube api", ch.ChannelID)
+	s.recordSaturationTrigger()
+	apiVideos, err := s.fetchUploads(ch, max(ch.FetchLimit, deepFetchMinResults))
+	if err != nil {
+		s.handleAPIError(ch.ChannelID, "fetch after rss saturation", err)
+		if errors.Is(err, repository.ErrYouTubeRateLimited) || errors.Is(err, repository.ErrYouTubeQuotaExceeded) {
+			s.recordAPIFallback()
+		}
+		return videos
+	}
+	s.recordAPIFetch()
+	return apiVideos
+}
+
+func (s *feedService) Seed(ch model.ChannelDTO, keepNewest bool) ([]model.VideoDTO, error) {
+	videos, validators, notModified, _, err := s.fetch(ch)
+	if err != nil {
+		return nil, err
+	}
+	if notModified {
+		// The feed has not changed since its videos were last processed.
+		return nil, nil
+	}
+	kept, err := s.seed(ch, videos, keepNewest)
+	if err != nil {
+		return nil, err
+	}
+	out, held, err := s.filterNew(ch, kept)
+	if err != nil {
+		return nil, err
+	}
+	if held == 0 {
+		s.stageValidators(ch.ChannelID, validators)
+	}
+	return out, nil
+}
+
+// fetch loads the channel's uploads from the API when its fetch_limit needs more than the RSS
+// window, falling back to RSS; fromAPI reports which source answered.
+func (s *feedService) fetch(ch model.ChannelDTO) (videos []model.VideoDTO, validators model.FeedValidators, notModified, fromAPI bool, err error) {
+	useYouTube := ch.FetchLimit >= rssMaxWindow && s.ytRepo != nil && s.apiAllowed()
 	if useYouTube {
-		videos, err = s.ytRepo.FetchUploads(ch.ChannelID, ch.FetchLimit)
+		videos, err = s.fetchUploads(ch, ch.FetchLimit)
 		if err == nil {
 			s.recordAPIFetch()
+			return videos, model.FeedValidators{}, false, true, nil
 		}
-	} else {
-		videos, err = s.rssRepo.Fetch(ch.ChannelID)
-		s.recordRSSFetch()
+		s.handleAPIError(ch.ChannelID, "fetch", err)
+		log.Printf("falling back to rss for channel=%s", ch.ChannelID)
+		s.recordAPIFallback()
+		defer s.recordRSSFallback()
 	}
-	if err != nil && useYouTube {
-		if errors.Is(err, repository.ErrYouTubeRateLimited) {
-			log.Printf("youtube api quota exceeded for channel=%s, falling back to rss", ch.ChannelID)
+	videos, validators, notModified, err = s.fetchRSS(ch.ChannelID)
+	return videos, validators, notModified, false, err
+}
+
+func (s *feedService) isFirstRun(ch model.ChannelDTO) (bool, error) {
+	if s.firstRun == FirstRunNotifyAll {
+		return false, nil
+	}
+	seen, err := s.notifiedRepo.HasChannel(ch.ChannelID)
+	return !seen, err
+}
+
+// seed records the videos as seen without notifying them. With keepNewest the most recent
+// upload is returned instead so that it alone is notified.
+func (s *feedService) seed(ch model.ChannelDTO, videos []model.VideoDTO, keepNewest bool) ([]model.VideoDTO, error) {
+	sorted := append([]model.VideoDTO(nil), videos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].PublishedAt.After(sorted[j].PublishedAt)
+	})
+	var kept []model.VideoDTO
+	if keepNewest && len(sorted) > 0 {
+		kept, sorted = sorted[:1], sorted[1:]
+	}
+	seeded := 0
+	for _, v := range sorted {
+		seen, err := s.notifiedRepo.Has(v.VideoID)
+		if err != nil {
+			return nil, err
+		}
+		if seen {
+			continue
+		}
+		rec := model.NotifiedRecord{VideoID: v.VideoID, ChannelID: v.ChannelID, PublishedAt: v.PublishedAt, NotifiedAt: time.Now(), Category: ch.Category}
+		if err := s.notifiedRepo.Append(rec); err != nil {
+			return nil, err
+		}
+		seeded++
+	}
+	s.recordSeeded(seeded)
+	log.Printf("seeded channel=%s: %d videos recorded without notification", ch.ChannelID, seeded)
+	return kept, nil
+}
+
+// FilterNew drops videos already in the history, enriches the rest via the API when available
+// and applies the kind filters, recording filtered videos as seen.
+func (s *feedService) FilterNew(ch model.ChannelDTO, videos []model.VideoDTO) ([]model.VideoDTO, error) {
+	out, _, err := s.filterNew(ch, videos)
+	return out, err
+}
+
+// filterNew is FilterNew that also reports how many videos were held back unseen.
+func (s *feedService) filterNew(ch model.ChannelDTO, videos []model.VideoDTO) (out []model.VideoDTO, held int, err error) {
+	var unseen []model.VideoDTO
+	for _, v := range videos {
+		seen, err := s.notifiedRepo.Has(v.VideoID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if seen {
+			continue
+		}
+		unseen = append(unseen, v)
+	}
+
+	if err := s.route(ch, unseen); err != nil {
+		return nil, 0, err
+	}
+
+	// Drop old videos before enrichment so they do not cost API units.
+	now := time.Now()
+	recent := unseen[:0]
+	for _, v := range unseen {
+		if limit := s.maxAgeFor(v.Category); !limit.IsZero() {
+			if cutoff := limit.Cutoff(now, s.location); v.PublishedAt.Before(cutoff) {
+				s.recordTooOld()
+				if err := s.markSeen(v, fmt.Sprintf("published %s, before %s", v.PublishedAt.Format(time.RFC3339), cutoff.Format(time.RFC3339))); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+		}
+		recent = append(recent, v)
+	}
+	unseen = recent
+
+	if unseen, err = s.applyRules(ch, unseen); err != nil {
+		return nil, 0, err
+	}
+
+	if s.ytRepo != nil && len(unseen) > 0 && s.apiAllowed() {
+		var enriched []model.VideoDTO
+		err := s.withAPIRetry("youtube api enrich", ch.ChannelID, func() (err error) {
+			enriched, err = s.ytRepo.EnrichVideos(unseen)
+			return err
+		})
+		if err != nil {
+			s.handleAPIError(ch.ChannelID, "enrich", err)
+			log.Printf("classifying videos for channel=%s from feed hints", ch.ChannelID)
 		} else {
-			log.Printf("youtube api fetch failed for channel=%s: %v; falling back to rss", ch.ChannelID, err)
+			unseen = enriched
 		}
-		s.recordAPIFallback()
-		s.recordRSSFetch()
-		videos, err = s.rssRepo.Fetch(ch.ChannelID)
-		s.recordRSSFallback()
 	}
+
+	for _, v := range unseen {
+		if !s.allowKind(v.Kind) {
+			s.recordFiltered()
+			if err := s.markSeen(v, fmt.Sprintf("kind=%s", v.Kind)); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		reason, hold := s.checkDuration(v.Category, v)
+		if hold {
+			s.recordDurationHeld()
+			held++
+			log.Printf("holding channel=%s video=%s until its duration is known", v.ChannelID, v.VideoID)
+			continue
+		}
+		if reason != "" {
+			s.recordDurationFiltered()
+			if err := s.markSeen(v, reason); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		prog, hold := s.rejectingFilter(ch, v)
+		if hold {
+			s.recordExprHeld()
+			held++
+			log.Printf("holding channel=%s video=%s until it can be enriched for filter: %s", v.ChannelID, v.VideoID, prog)
+			continue
+		}
+		if prog != nil {
+			s.recordExprFiltered()
+			if err := s.markSeen(v, "filter: "+prog.String()); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		out = append(out, v)
+	}
+	// Language routing comes last so that every per-category setting above is looked up by
+	// the base category.
+	s.routeLanguage(out)
+	return out, held, nil
+}
+
+// checkDuration applies the category's min/max_duration. It returns a reason when v is out of
+// range, or hold when the duration is unknown and unknown_duration is hold. Live streams and
+// premieres have no final length yet and always pass.
+func (s *feedService) checkDuration(category string, v model.VideoDTO) (reason string, hold bool) {
+	category = strings.ToLower(category)
+	minDur, maxDur := s.minDuration, s.maxDuration
+	if d, ok := s.categoryMinDur[category]; ok {
+		minDur = d
+	}
+	if d, ok := s.categoryMaxDur[category]; ok {
+		maxDur = d
+	}
+	if minDur == 0 && maxDur == 0 || v.Kind == model.VideoKindLive || v.Kind == model.VideoKindPremiere {
+		return "", false
+	}
+	if !v.Enriched {
+		return "", s.unknownDuration == UnknownDurationHold
+	}
+	if minDur > 0 && v.Duration < minDur {
+		return fmt.Sprintf("duration=%s below min_duration=%s", v.Duration, minDur), false
+	}
+	if maxDur > 0 && v.Duration > maxDur {
+		return fmt.Sprintf("duration=%s above max_duration=%s", v.Duration, maxDur), false
+	}
+	return "", false
+}
+
+// fetchRSS downloads the feed conditionally; notModified reports a 304 for an unchanged feed.
+func (s *feedService) fetchRSS(channelID string) (videos []model.VideoDTO, validators model.FeedValidators, notModified bool, err error) {
+	s.recordRSSFetch()
+	var prev model.FeedValidators
+	if s.validatorRepo != nil {
+		if prev, err = s.validatorRepo.Get(channelID); err != nil {
+			return nil, model.FeedValidators{}, false, err
+		}
+	}
+	err = s.withRetry("rss fetch", channelID, func() (err error) {
+		videos, validators, err = s.rssRepo.Fetch(channelID, prev)
+		return err
+	})
+	if errors.Is(err, repository.ErrFeedNotModified) {
+		s.recordNotModified()
+		return nil, prev, true, nil
+	}
+	return videos, validators, false, err
+}
+
+// fetchUploads reads up to limit uploads from the API, stopping at the newest video already in
+// the history so that unchanged channels cost a single page.
+func (s *feedService) fetchUploads(ch model.ChannelDTO, limit int) (videos []model.VideoDTO, err error) {
+	var since repository.UploadCursor
+	latest, ok, err := s.notifiedRepo.LatestForChannel(ch.ChannelID)
 	if err != nil {
 		return nil, err
 	}
+	if ok {
+		// Scheduled streams carry a future publish time; never let the cursor run ahead of when
+		// the video was recorded or it would hide real uploads.
+		at := latest.PublishedAt
+		if !latest.NotifiedAt.IsZero() && latest.NotifiedAt.Before(at) {
+			at = latest.NotifiedAt
+		}
+		since = repository.UploadCursor{VideoID: latest.VideoID, PublishedAt: at}
+	}
+	err = s.withAPIRetry("youtube api fetch", ch.ChannelID, func() (err error) {
+		videos, err = s.ytRepo.FetchUploads(ch.ChannelID, limit, since)
+		return err
+	})
+	return videos, err
+}
 
-	// If the RSS feed is saturated (15 items) we might miss uploads, so escalate to API when available.
-	if !useYouTube && s.ytRepo != nil && len(videos) >= rssMaxWindow {
-		log.Printf("rss feed saturated for channel=%s; attempting youtube api", ch.ChannelID)
-		s.recordSaturationTrigger()
-		apiVideos, apiErr := s.ytRepo.FetchUploads(ch.ChannelID, ch.FetchLimit)
-		if apiErr == nil {
-			videos = apiVideos
-			s.recordAPIFetch()
-		} else {
-			log.Printf("failed to load youtube api feed after rss saturation for channel=%s: %v", ch.ChannelID, apiErr)
-			if errors.Is(apiErr, repository.ErrYouTubeRateLimited) {
-				s.recordAPIFallback()
+// apiAllowed reports whether the Data API may be used, degrading to RSS-only once the
+// daily budget minus the reserve is spent.
+func (s *feedService) apiAllowed() bool {
+	s.mu.Lock()
+	off := s.apiOff
+	s.mu.Unlock()
+	if off {
+		return false
+	}
+	if s.quota.Ledger == nil || s.quota.DailyQuota <= 0 {
+		return true
+	}
+	usage, err := s.quota.Ledger.Today()
+	if err != nil {
+		log.Printf("failed to read youtube quota ledger: %v", err)
+		return true
+	}
+	limit := s.quota.DailyQuota - s.quota.Reserve
+	if usage.Units < limit {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.QuotaSkips++
+	if !s.quotaSpent {
+		s.quotaSpent = true
+		log.Printf("youtube quota budget spent for %s (%d/%d units, reserve %d); using rss only", usage.Day, usage.Units, s.quota.DailyQuota, s.quota.Reserve)
+	}
+	return false
+}
+
+// handleAPIError logs a failed API call by cause. Errors that will not go away within this
+// run (quota exhausted on every key, a rejected key, the API not enabled) switch the API off
+// so the remaining channels go straight to RSS.
+func (s *feedService) handleAPIError(channelID, op string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrYouTubeQuotaExceeded):
+		s.disableAPI(fmt.Sprintf("quota exceeded: %v", err))
+	case errors.Is(err, repository.ErrYouTubeInvalidKey):
+		s.disableAPI(fmt.Sprintf("api key rejected: %v", err))
+	case errors.Is(err, repository.ErrYouTubeForbidden):
+		s.disableAPI(fmt.Sprintf("api access forbidden: %v", err))
+	case errors.Is(err, repository.ErrYouTubeRateLimited):
+		log.Printf("youtube api %s rate limited for channel=%s: %v", op, channelID, err)
+	case errors.Is(err, repository.ErrYouTubePlaylistNotFound):
+		log.Printf("youtube api %s found no uploads playlist for channel=%s", op, channelID)
+	default:
+		log.Printf("youtube api %s failed for channel=%s: %v", op, channelID, err)
+	}
+}
+
+func (s *feedService) disableAPI(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.apiOff {
+		return
+	}
+	s.apiOff = true
+	log.Printf("youtube api disabled for the rest of the run (%s)", reason)
+}
+
+// stageValidators keeps the validators of a successfully processed feed until Commit, so a run
+// that dies halfway re-downloads the feeds instead of trusting a 304 for videos never handled.
+func (s *feedService) stageValidators(channelID string, v model.FeedValidators) {
+	if s.validatorRepo == nil || v.IsZero() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators[channelID] = v
+}
+
+func (s *feedService) Commit() error {
+	if s.quota.Ledger != nil {
+		if err := s.quota.Ledger.Commit(); err != nil {
+			return fmt.Errorf("save youtube quota ledger: %w", err)
+		}
+	}
+	if s.validatorRepo == nil {
+		return nil
+	}
+	s.mu.Lock()
+	staged := s.validators
+	s.validators = map[string]model.FeedValidators{}
+	s.mu.Unlock()
+	return s.validatorRepo.Save(staged)
+}
+
+// route sets the category of each video: the first matching route of the channel, then of
+// its category, then of "*", or else the channel's own category.
+func (s *feedService) route(ch model.ChannelDTO, videos []model.VideoDTO) error {
+	channelRoutes, err := ParseRoutes(ch.Routes)
+	if err != nil {
+		return fmt.Errorf("routes of channel=%s: %w", ch.ChannelID, err)
+	}
+	var routes []Route
+	routes = append(routes, channelRoutes...)
+	routes = append(routes, s.categoryRoutes[strings.ToLower(ch.Category)]...)
+	routes = append(routes, s.categoryRoutes["*"]...)
+	for i := range videos {
+		videos[i].Category = ch.Category
+		for _, r := range routes {
+			if !r.Rule.Match(videos[i], ch.Name) {
+				continue
 			}
+			if r.Category != ch.Category {
+				videos[i].Category = r.Category
+				s.recordRouted()
+				log.Printf("routing channel=%s video=%s to category=%s (rule: %s)", ch.ChannelID, videos[i].VideoID, r.Category, r.Rule.Source)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// routeLanguage moves videos of a language-routed base category to its _jp or _en variant. It
+// runs after enrichment so that the API's language fields can be used, and after the filters.
+func (s *feedService) routeLanguage(videos []model.VideoDTO) {
+	for i, v := range videos {
+		category, lang, source, ok := s.languageRouting.Category(v.Category, v)
+		if !ok {
+			continue
 		}
+		videos[i].Category = category
+		s.recordLanguageRouted()
+		log.Printf("routing channel=%s video=%s to category=%s (language=%s from %s)", v.ChannelID, v.VideoID, category, lang, source)
 	}
+}
 
+// applyRules runs the category and channel rules over videos, logging every decision and
+// recording dropped videos as seen.
+func (s *feedService) applyRules(ch model.ChannelDTO, videos []model.VideoDTO) ([]model.VideoDTO, error) {
+	channelRules, err := ParseRuleSet(ch.IncludeRules, ch.ExcludeRules)
+	if err != nil {
+		return nil, fmt.Errorf("rules of channel=%s: %w", ch.ChannelID, err)
+	}
 	var out []model.VideoDTO
 	for _, v := range videos {
-		seen, err := s.notifiedRepo.Has(v.VideoID)
-		if err != nil {
-			return nil, err
+		rules := s.categoryRules[strings.ToLower(v.Category)].merge(channelRules)
+		if rules.IsZero() {
+			out = append(out, v)
+			continue
 		}
-		if seen {
+		keep, reason := rules.Decide(v, ch.Name)
+		if !keep {
+			s.recordRuleExcluded()
+			if err := s.markSeen(v, "rule: "+reason); err != nil {
+				return nil, err
+			}
 			continue
 		}
-		// TODO: includeLive/includePremieres/includeShorts に応じたフィルタを追加
+		log.Printf("keeping channel=%s video=%s (rule: %s)", v.ChannelID, v.VideoID, reason)
 		out = append(out, v)
 	}
 	return out, nil
 }
 
+// rejectingFilter returns the category or channel filter expression that v fails, if any. An
+// expression whose outcome depends on enrichment fields v lacks passes, or with
+// unknown_duration hold it is returned with hold set.
+func (s *feedService) rejectingFilter(ch model.ChannelDTO, v model.VideoDTO) (rejecting *expr.Program, hold bool) {
+	now := time.Now()
+	var undecided *expr.Program
+	for _, prog := range []*expr.Program{s.categoryFilters[strings.ToLower(v.Category)], s.channelFilters[ch.ChannelID]} {
+		if prog == nil {
+			continue
+		}
+		switch prog.Eval(v, now) {
+		case expr.False:
+			return prog, false
+		case expr.Unknown:
+			if undecided == nil {
+				undecided = prog
+			}
+		}
+	}
+	if undecided != nil && s.unknownDuration == UnknownDurationHold {
+		return undecided, true
+	}
+	return nil, false
+}
+
+func (s *feedService) maxAgeFor(category string) MaxAge {
+	if limit, ok := s.categoryMaxAge[strings.ToLower(category)]; ok {
+		return limit
+	}
+	return s.maxAge
+}
+
+func (s *feedService) allowKind(kind model.VideoKind) bool {
+	switch kind {
+	case model.VideoKindShort:
+		return s.includeShorts
+	case model.VideoKindLive, model.VideoKindLiveArchive:
+		return s.includeLive
+	case model.VideoKindPremiere:
+		return s.includePremieres
+	default:
+		return true
+	}
+}
+
+// markSeen records a video that was skipped on purpose so it is not re-evaluated on the next run.
+func (s *feedService) markSeen(v model.VideoDTO, reason string) error {
+	log.Printf("skipping channel=%s video=%s (%s)", v.ChannelID, v.VideoID, reason)
+	return s.notifiedRepo.Append(model.NotifiedRecord{
+		VideoID: v.VideoID, ChannelID: v.ChannelID, PublishedAt: v.PublishedAt, NotifiedAt: time.Now(), Category: v.Category,
+	})
+}
+
 func (s *feedService) Stats() FeedStats {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -138,3 +738,81 @@ func (s *feedService) recordSaturationTrigger() {
 	defer s.mu.Unlock()
 	s.stats.SaturationTriggers++
 }
+
+func (s *feedService) recordFiltered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Filtered++
+}
+
+func (s *feedService) recordNotModified() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.NotModified++
+}
+
+func (s *feedService) recordRetry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Retries++
+}
+
+func (s *feedService) recordRetryExhausted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.RetryExhausted++
+}
+
+func (s *feedService) recordSeeded(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Seeded += n
+}
+
+func (s *feedService) recordTooOld() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.TooOld++
+}
+
+func (s *feedService) recordRuleExcluded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.RuleExcluded++
+}
+
+func (s *feedService) recordExprFiltered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.ExprFiltered++
+}
+
+func (s *feedService) recordDurationFiltered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.DurationFiltered++
+}
+
+func (s *feedService) recordDurationHeld() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.DurationHeld++
+}
+
+func (s *feedService) recordExprHeld() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.ExprHeld++
+}
+
+func (s *feedService) recordRouted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Routed++
+}
+
+func (s *feedService) recordLanguageRouted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LanguageRouted++
+}
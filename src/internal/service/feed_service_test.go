@@ -0,0 +1,350 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/expr"
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+type scriptedFeedRepository struct {
+	errs   []error
+	videos []model.VideoDTO // returned on success; defaults to a single VID1
+	calls  int
+}
+
+func (r *scriptedFeedRepository) Fetch(channelID string, _ model.FeedValidators) ([]model.VideoDTO, model.FeedValidators, error) {
+	r.calls++
+	if r.calls <= len(r.errs) && r.errs[r.calls-1] != nil {
+		return nil, model.FeedValidators{}, r.errs[r.calls-1]
+	}
+	if r.videos != nil {
+		return r.videos, model.FeedValidators{}, nil
+	}
+	return []model.VideoDTO{{VideoID: "VID1", ChannelID: channelID, PublishedAt: time.Now()}}, model.FeedValidators{}, nil
+}
+
+func TestFeedRetriesTransientErrors(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	rss := &scriptedFeedRepository{errs: []error{
+		&repository.FeedHTTPError{StatusCode: 503},
+		&repository.FeedHTTPError{StatusCode: 429, RetryAfter: 5 * time.Second},
+	}}
+	svc := NewFeedService(rss, nil, notified, nil, FeedOptions{Retry: policy, FirstRun: FirstRunNotifyAll}).(*feedService)
+	var waits []time.Duration
+	svc.sleep = func(d time.Duration) { waits = append(waits, d) }
+	svc.jitter = func(time.Duration) time.Duration { return 0 }
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", FetchLimit: 5})
+	if err != nil {
+		t.Fatalf("ListNewVideos error: %v", err)
+	}
+	if len(videos) != 1 || rss.calls != 3 {
+		t.Fatalf("got %d videos after %d calls, want 1 after 3", len(videos), rss.calls)
+	}
+	if len(waits) != 2 || waits[0] != 500*time.Millisecond || waits[1] != 5*time.Second {
+		t.Fatalf("unexpected waits %v", waits)
+	}
+	if stats := svc.Stats(); stats.Retries != 2 || stats.RetryExhausted != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	missing := &scriptedFeedRepository{errs: []error{&repository.FeedHTTPError{StatusCode: 404}}}
+	svc = NewFeedService(missing, nil, notified, nil, FeedOptions{Retry: policy, FirstRun: FirstRunNotifyAll}).(*feedService)
+	svc.sleep = func(time.Duration) { t.Fatal("404 must not be retried") }
+	if _, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC2", FetchLimit: 5}); err == nil || missing.calls != 1 {
+		t.Fatalf("expected a single failed call, got err=%v calls=%d", err, missing.calls)
+	}
+}
+
+func TestFeedSeedsNewChannel(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rss := &scriptedFeedRepository{}
+	for i, id := range []string{"OLD", "NEWEST", "MIDDLE"} {
+		offset := []int{0, 2, 1}[i]
+		rss.videos = append(rss.videos, model.VideoDTO{VideoID: id, ChannelID: "UC1", PublishedAt: base.Add(time.Duration(offset) * time.Hour)})
+	}
+	svc := NewFeedService(rss, nil, notified, nil, FeedOptions{FirstRun: FirstRunNewest})
+	ch := model.ChannelDTO{ChannelID: "UC1", FetchLimit: 5}
+
+	videos, err := svc.ListNewVideos(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "NEWEST" {
+		t.Fatalf("first run should keep only the newest video, got %+v", videos)
+	}
+	if seen, _ := notified.Has("NEWEST"); seen {
+		t.Fatal("the kept video must be left for the notify service")
+	}
+	if err := notified.Append(model.NotifiedRecord{VideoID: "NEWEST", ChannelID: "UC1", PublishedAt: base, NotifiedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	rss.videos = append(rss.videos, model.VideoDTO{VideoID: "UPLOAD", ChannelID: "UC1", PublishedAt: base.Add(3 * time.Hour)})
+	videos, err = svc.ListNewVideos(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "UPLOAD" {
+		t.Fatalf("later runs should only return new uploads, got %+v", videos)
+	}
+	if stats := svc.Stats(); stats.Seeded != 2 {
+		t.Fatalf("expected 2 seeded videos, got %+v", stats)
+	}
+}
+
+func TestFeedSkipsVideosOlderThanMaxAge(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		{VideoID: "FRESH", ChannelID: "UC1", PublishedAt: now.Add(-time.Hour)},
+		{VideoID: "STALE", ChannelID: "UC1", PublishedAt: now.Add(-72 * time.Hour)},
+	}}
+	svc := NewFeedService(rss, nil, notified, nil, FeedOptions{
+		FirstRun:       FirstRunNotifyAll,
+		MaxAge:         MaxAge{Duration: 48 * time.Hour},
+		CategoryMaxAge: map[string]MaxAge{"archive": {}},
+	})
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", FetchLimit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "FRESH" {
+		t.Fatalf("expected only the fresh video, got %+v", videos)
+	}
+	if seen, _ := notified.Has("STALE"); !seen {
+		t.Fatal("a video past max_age should be marked as seen")
+	}
+	if stats := svc.Stats(); stats.TooOld != 1 {
+		t.Fatalf("expected 1 too-old video, got %+v", stats)
+	}
+
+	rss.videos = append(rss.videos, model.VideoDTO{VideoID: "OLDER", ChannelID: "UC2", PublishedAt: now.Add(-96 * time.Hour)})
+	videos, err = svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC2", Category: "Archive", FetchLimit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 2 {
+		t.Fatalf("a zero category override should lift the limit, got %+v", videos)
+	}
+}
+
+func TestMaxAgeCutoff(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC) // 10:30 JST
+	cases := []struct {
+		raw  string
+		want time.Time
+	}{
+		{"72h", now.Add(-72 * time.Hour)},
+		{"1d", time.Date(2024, 3, 10, 0, 0, 0, 0, tokyo)},
+		{"3d", time.Date(2024, 3, 8, 0, 0, 0, 0, tokyo)},
+	}
+	for _, c := range cases {
+		limit, err := ParseMaxAge(c.raw)
+		if err != nil {
+			t.Fatalf("ParseMaxAge(%q): %v", c.raw, err)
+		}
+		if got := limit.Cutoff(now, tokyo); !got.Equal(c.want) {
+			t.Errorf("%s: cutoff %v, want %v", c.raw, got, c.want)
+		}
+	}
+	for _, raw := range []string{"0d", "-1h", "week"} {
+		if _, err := ParseMaxAge(raw); err == nil {
+			t.Errorf("ParseMaxAge(%q) should fail", raw)
+		}
+	}
+}
+
+type countingYouTubeRepository struct {
+	fetches int
+	since   repository.UploadCursor
+}
+
+func (r *countingYouTubeRepository) FetchUploads(channelID string, _ int, since repository.UploadCursor) ([]model.VideoDTO, error) {
+	r.fetches++
+	r.since = since
+	return nil, nil
+}
+
+func (r *countingYouTubeRepository) EnrichVideos(videos []model.VideoDTO) ([]model.VideoDTO, error) {
+	return videos, nil
+}
+
+func TestFeedDeepFetchesOnlyWhenWindowIsUnseen(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	rss := &scriptedFeedRepository{}
+	for i := 0; i < rssMaxWindow; i++ {
+		rss.videos = append(rss.videos, model.VideoDTO{VideoID: fmt.Sprintf("VID%02d", i), ChannelID: "UC1", PublishedAt: base.Add(-time.Duration(i) * time.Minute)})
+	}
+	if err := notified.Append(model.NotifiedRecord{VideoID: "VID14", ChannelID: "UC1", PublishedAt: rss.videos[14].PublishedAt, NotifiedAt: base}); err != nil {
+		t.Fatal(err)
+	}
+	yt := &countingYouTubeRepository{}
+	svc := NewFeedService(rss, yt, notified, nil, FeedOptions{IncludeShorts: true})
+	ch := model.ChannelDTO{ChannelID: "UC1", FetchLimit: 5}
+
+	if _, err := svc.ListNewVideos(ch); err != nil {
+		t.Fatal(err)
+	}
+	if yt.fetches != 0 {
+		t.Fatal("a window that still contains a known video must not trigger a deep fetch")
+	}
+
+	// Fifteen uploads since the last run push every known video out of the window.
+	for i := range rss.videos {
+		rss.videos[i].VideoID = fmt.Sprintf("NEW%02d", i)
+	}
+	if _, err := svc.ListNewVideos(ch); err != nil {
+		t.Fatal(err)
+	}
+	if yt.fetches != 1 || yt.since.VideoID != "VID14" {
+		t.Fatalf("expected one deep fetch from the newest known video, got %d with cursor %+v", yt.fetches, yt.since)
+	}
+}
+
+type durationYouTubeRepository struct {
+	durations map[string]time.Duration
+	fail      bool
+}
+
+func (r *durationYouTubeRepository) FetchUploads(string, int, repository.UploadCursor) ([]model.VideoDTO, error) {
+	return nil, nil
+}
+
+func (r *durationYouTubeRepository) EnrichVideos(videos []model.VideoDTO) ([]model.VideoDTO, error) {
+	if r.fail {
+		return nil, &repository.YouTubeAPIError{Err: repository.ErrYouTubeForbidden, StatusCode: 403}
+	}
+	out := append([]model.VideoDTO(nil), videos...)
+	for i := range out {
+		out[i].Enriched = true
+		out[i].Kind = model.VideoKindRegular
+		out[i].Duration = r.durations[out[i].VideoID]
+	}
+	return out, nil
+}
+
+func TestFeedDurationLimits(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		{VideoID: "CLIP", ChannelID: "UC1", PublishedAt: now},
+		{VideoID: "EPISODE", ChannelID: "UC1", PublishedAt: now},
+		{VideoID: "ARCHIVE", ChannelID: "UC1", PublishedAt: now},
+	}}
+	yt := &durationYouTubeRepository{durations: map[string]time.Duration{
+		"CLIP": 30 * time.Second, "EPISODE": 20 * time.Minute, "ARCHIVE": 5 * time.Hour,
+	}}
+	opts := FeedOptions{
+		FirstRun:            FirstRunNotifyAll,
+		MinDuration:         time.Minute,
+		CategoryMaxDuration: map[string]time.Duration{"talk": 4 * time.Hour},
+		UnknownDuration:     UnknownDurationHold,
+	}
+	svc := NewFeedService(rss, yt, notified, nil, opts)
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", Category: "Talk", FetchLimit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "EPISODE" {
+		t.Fatalf("expected only EPISODE, got %+v", videos)
+	}
+	if stats := svc.Stats(); stats.DurationFiltered != 2 {
+		t.Fatalf("expected 2 videos out of range, got %+v", stats)
+	}
+
+	rss.videos = []model.VideoDTO{{VideoID: "PENDING", ChannelID: "UC2", PublishedAt: now}}
+	yt.fail = true
+	svc = NewFeedService(rss, yt, notified, nil, opts)
+	if videos, err = svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC2", FetchLimit: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := notified.Has("PENDING"); len(videos) != 0 || seen {
+		t.Fatalf("a video of unknown length should be held unseen, got %+v (seen=%v)", videos, seen)
+	}
+	if stats := svc.Stats(); stats.DurationHeld != 1 {
+		t.Fatalf("expected 1 held video, got %+v", stats)
+	}
+}
+
+func TestFeedFilterExpressionOnUnenrichedVideos(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	prog, err := expr.Compile(`duration > 5m && !title.contains('#shorts')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		{VideoID: "TALK", ChannelID: "UC1", Title: "Long talk", PublishedAt: now},
+		{VideoID: "TAGGED", ChannelID: "UC1", Title: "Clip #shorts", PublishedAt: now},
+	}}
+	yt := &durationYouTubeRepository{fail: true}
+	opts := FeedOptions{
+		FirstRun:        FirstRunNotifyAll,
+		CategoryFilters: map[string]*expr.Program{"talk": prog},
+	}
+	ch := model.ChannelDTO{ChannelID: "UC1", Category: "Talk", FetchLimit: 5}
+
+	videos, err := NewFeedService(rss, yt, notified, nil, opts).ListNewVideos(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "TALK" {
+		t.Fatalf("an unenriched video the filter cannot judge should pass by default, got %+v", videos)
+	}
+	if seen, _ := notified.Has("TAGGED"); !seen {
+		t.Fatal("a clause on feed fields should still reject an unenriched video")
+	}
+
+	rss.videos = []model.VideoDTO{{VideoID: "PENDING", ChannelID: "UC1", Title: "Another talk", PublishedAt: now}}
+	opts.UnknownDuration = UnknownDurationHold
+	svc := NewFeedService(rss, yt, notified, nil, opts)
+	if videos, err = svc.ListNewVideos(ch); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := notified.Has("PENDING"); len(videos) != 0 || seen {
+		t.Fatalf("an unenriched video should be held unseen, got %+v (seen=%v)", videos, seen)
+	}
+	if stats := svc.Stats(); stats.ExprHeld != 1 || stats.ExprFiltered != 0 {
+		t.Fatalf("expected 1 held video, got %+v", stats)
+	}
+
+	yt.fail = false
+	yt.durations = map[string]time.Duration{"PENDING": time.Minute}
+	if videos, err = NewFeedService(rss, yt, notified, nil, opts).ListNewVideos(ch); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := notified.Has("PENDING"); len(videos) != 0 || !seen {
+		t.Fatalf("once enriched the filter should reject the video, got %+v (seen=%v)", videos, seen)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+// Language is the suffix of a language-specific category, e.g. tech_jp.
+type Language string
+
+const (
+	LanguageJP Language = "jp"
+	LanguageEN Language = "en"
+)
+
+// LanguageRouting sends the videos of a base category such as tech to tech_jp or tech_en.
+type LanguageRouting struct {
+	Categories map[string]bool // lowercased base categories
+	Fallback   Language        // used when neither the API nor the title tells the language
+}
+
+// Category returns the language-specific category for base, or ok=false when base is not
+// routed by language. source says what decided the language, for logs.
+func (lr LanguageRouting) Category(base string, v model.VideoDTO) (category string, lang Language, source string, ok bool) {
+	if !lr.Categories[strings.ToLower(base)] {
+		return "", "", "", false
+	}
+	lang, source = videoLanguage(v)
+	if lang == "" {
+		lang, source = lr.Fallback, "fallback"
+	}
+	return strings.ToLower(base) + "_" + string(lang), lang, source, true
+}
+
+// videoLanguage prefers the languages the uploader set, then guesses from the title's script.
+func videoLanguage(v model.VideoDTO) (Language, string) {
+	if lang := languageFromCode(v.DefaultAudioLanguage); lang != "" {
+		return lang, "defaultAudioLanguage"
+	}
+	if lang := languageFromCode(v.DefaultLanguage); lang != "" {
+		return lang, "defaultLanguage"
+	}
+	if lang := titleLanguage(v.Title); lang != "" {
+		return lang, "title"
+	}
+	return "", ""
+}
+
+// languageFromCode maps BCP-47 codes such as ja, ja-JP or en-GB; other languages are unknown.
+func languageFromCode(code string) Language {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(code)), "-")
+	switch primary {
+	case "ja":
+		return LanguageJP
+	case "en":
+		return LanguageEN
+	}
+	return ""
+}
+
+// titleLanguage treats any kana or kanji as Japanese, since Japanese titles often mix in
+// English words, and a title with Latin letters only as English.
+func titleLanguage(title string) Language {
+	latin := false
+	for _, r := range title {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han):
+			return LanguageJP
+		case unicode.In(r, unicode.Latin):
+			latin = true
+		}
+	}
+	if latin {
+		return LanguageEN
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/expr"
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+func TestFeedRoutesVideosByLanguage(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		// The API's audio language wins over the title's script.
+		{VideoID: "AUDIO", ChannelID: "UC1", Title: "Goの並行処理", DefaultAudioLanguage: "en-US"},
+		{VideoID: "META", ChannelID: "UC1", Title: "Go generics", DefaultLanguage: "ja"},
+		{VideoID: "KANA", ChannelID: "UC1", Title: "Go generics 入門"},
+		{VideoID: "LATIN", ChannelID: "UC1", Title: "Intro to Go generics"},
+		{VideoID: "UNKNOWN", ChannelID: "UC1", Title: "2024 🎉", DefaultAudioLanguage: "ko"},
+	}}
+	svc := NewFeedService(rss, nil, notified, nil, FeedOptions{
+		FirstRun:        FirstRunNotifyAll,
+		LanguageRouting: LanguageRouting{Categories: map[string]bool{"tech": true}, Fallback: LanguageEN},
+	})
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", Category: "Tech", FetchLimit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, v := range videos {
+		got[v.VideoID] = v.Category
+	}
+	want := map[string]string{"AUDIO": "tech_en", "META": "tech_jp", "KANA": "tech_jp", "LATIN": "tech_en", "UNKNOWN": "tech_en"}
+	if len(got) != len(want) {
+		t.Fatalf("got categories %v, want %v", got, want)
+	}
+	for id, category := range want {
+		if got[id] != category {
+			t.Fatalf("got categories %v, want %v", got, want)
+		}
+	}
+	if stats := svc.Stats(); stats.LanguageRouted != 5 {
+		t.Fatalf("language_routed = %d, want 5", stats.LanguageRouted)
+	}
+}
+
+func TestFeedLanguageRoutingKeepsBaseCategorySettings(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notClip, err := expr.Compile(`!title.contains("clip")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		{VideoID: "TALK", ChannelID: "UC1", Title: "Go generics 入門", PublishedAt: now},
+		{VideoID: "SHORT", ChannelID: "UC1", Title: "Go tips", PublishedAt: now},
+		{VideoID: "CLIP", ChannelID: "UC1", Title: "Go clip", PublishedAt: now},
+	}}
+	yt := &durationYouTubeRepository{durations: map[string]time.Duration{
+		"TALK": 20 * time.Minute, "SHORT": 30 * time.Second, "CLIP": 20 * time.Minute,
+	}}
+	svc := NewFeedService(rss, yt, notified, nil, FeedOptions{
+		FirstRun:            FirstRunNotifyAll,
+		CategoryMinDuration: map[string]time.Duration{"tech": time.Minute},
+		CategoryFilters:     map[string]*expr.Program{"tech": notClip},
+		LanguageRouting:     LanguageRouting{Categories: map[string]bool{"tech": true}, Fallback: LanguageEN},
+	})
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", Category: "tech", FetchLimit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "TALK" || videos[0].Category != "tech_jp" {
+		t.Fatalf("expected only TALK routed to tech_jp, got %+v", videos)
+	}
+	if stats := svc.Stats(); stats.DurationFiltered != 1 || stats.ExprFiltered != 1 || stats.LanguageRouted != 1 {
+		t.Fatalf("the base category's limits should apply, got %+v", stats)
+	}
+}
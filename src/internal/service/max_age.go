@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxAge is a recency limit for notifications: either a plain duration ("72h") or a number of
+// calendar days ("3d" keeps today and the two days before it, "1d" only today).
+type MaxAge struct {
+	Duration time.Duration
+	Days     int
+}
+
+func ParseMaxAge(s string) (MaxAge, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return MaxAge{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return MaxAge{}, fmt.Errorf("invalid max_age %q: want a duration like 72h or days like 3d", s)
+		}
+		return MaxAge{Days: n}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return MaxAge{}, fmt.Errorf("invalid max_age %q: want a duration like 72h or days like 3d", s)
+	}
+	return MaxAge{Duration: d}, nil
+}
+
+func (m MaxAge) IsZero() bool {
+	return m.Duration == 0 && m.Days == 0
+}
+
+// Cutoff returns the oldest publish time still notified at now; day windows use loc.
+func (m MaxAge) Cutoff(now time.Time, loc *time.Location) time.Time {
+	if m.Duration > 0 {
+		return now.Add(-m.Duration)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return midnight.AddDate(0, 0, -(m.Days - 1))
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+// MigrateService copies channels, notification history and the outbox (including the dead-letter
+// queue) from one storage backend to another.
+type MigrateService interface {
+	Migrate(dryRun bool) (MigrationReport, error)
+}
+
+// MigrationReport counts what was copied. Rows already present in the target with identical
+// content are skipped; rows present with different content are conflicts and keep the target's value.
+type MigrationReport struct {
+	ChannelsRead      int
+	ChannelsCopied    int
+	ChannelsSkipped   int
+	ChannelConflicts  []string
+	NotifiedRead      int
+	NotifiedCopied    int
+	NotifiedSkipped   int
+	NotifiedConflicts []string
+	OutboxRead        int
+	OutboxCopied      int
+	OutboxSkipped     int
+	OutboxConflicts   []string
+	ChannelsInTarget  int
+	NotifiedInTarget  int
+	OutboxInTarget    int
+}
+
+type migrateService struct {
+	srcChannels repository.ChannelStore
+	srcNotified repository.NotifiedStore
+	dstChannels repository.ChannelStore
+	dstNotified repository.NotifiedStore
+	srcOutbox   repository.OutboxRepository
+	dstOutbox   repository.OutboxRepository
+}
+
+func NewMigrateService(srcChannels repository.ChannelStore, srcNotified repository.NotifiedStore, srcOutbox repository.OutboxRepository,
+	dstChannels repository.ChannelStore, dstNotified repository.NotifiedStore, dstOutbox repository.OutboxRepository) MigrateService {
+	return &migrateService{
+		srcChannels: srcChannels, srcNotified: srcNotified, srcOutbox: srcOutbox,
+		dstChannels: dstChannels, dstNotified: dstNotified, dstOutbox: dstOutbox,
+	}
+}
+
+func (s *migrateService) Migrate(dryRun bool) (MigrationReport, error) {
+	var report MigrationReport
+	if err := s.migrateChannels(&report, dryRun); err != nil {
+		return report, err
+	}
+	if err := s.migrateNotified(&report, dryRun); err != nil {
+		return report, err
+	}
+	if err := s.migrateOutbox(&report, dryRun); err != nil {
+		return report, err
+	}
+	if dryRun {
+		return report, nil
+	}
+	return report, s.verify(&report)
+}
+
+func (s *migrateService) migrateChannels(report *MigrationReport, dryRun bool) error {
+	src, err := s.srcChannels.ListAll()
+	if err != nil {
+		return fmt.Errorf("read source channels: %w", err)
+	}
+	dst, err := s.dstChannels.ListAll()
+	if err != nil {
+		return fmt.Errorf("read target channels: %w", err)
+	}
+	existing := make(map[string]model.ChannelDTO, len(dst))
+	for _, ch := range dst {
+		existing[ch.ChannelID] = ch
+	}
+
+	report.ChannelsRead = len(src)
+	for _, ch := range src {
+		if cur, ok := existing[ch.ChannelID]; ok {
+			if cur == ch {
+				report.ChannelsSkipped++
+			} else {
+				report.ChannelConflicts = append(report.ChannelConflicts,
+					fmt.Sprintf("channel %s: source=%+v target=%+v", ch.ChannelID, ch, cur))
+			}
+			continue
+		}
+		if !dryRun {
+			if err := s.dstChannels.Add(ch); err != nil {
+				return fmt.Errorf("write channel %s: %w", ch.ChannelID, err)
+			}
+		}
+		existing[ch.ChannelID] = ch
+		report.ChannelsCopied++
+	}
+	return nil
+}
+
+func (s *migrateService) migrateNotified(report *MigrationReport, dryRun bool) error {
+	src, err := s.srcNotified.ListAll()
+	if err != nil {
+		return fmt.Errorf("read source history: %w", err)
+	}
+	dst, err := s.dstNotified.ListAll()
+	if err != nil {
+		return fmt.Errorf("read target history: %w", err)
+	}
+	existing := make(map[string]model.NotifiedRecord, len(dst))
+	for _, rec := range dst {
+		existing[rec.VideoID] = rec
+	}
+
+	report.NotifiedRead = len(src)
+	for _, rec := range src {
+		if cur, ok := existing[rec.VideoID]; ok {
+			if sameNotified(cur, rec) {
+				report.NotifiedSkipped++
+			} else {
+				report.NotifiedConflicts = append(report.NotifiedConflicts,
+					fmt.Sprintf("video %s: source channel=%s published=%s target channel=%s published=%s",
+						rec.VideoID, rec.ChannelID, rec.PublishedAt.Format(time.RFC3339), cur.ChannelID, cur.PublishedAt.Format(time.RFC3339)))
+			}
+			continue
+		}
+		if !dryRun {
+			if err := s.dstNotified.Append(rec); err != nil {
+				return fmt.Errorf("write history %s: %w", rec.VideoID, err)
+			}
+		}
+		existing[rec.VideoID] = rec
+		report.NotifiedCopied++
+	}
+	return nil
+}
+
+// migrateOutbox copies pending, sent and dead-lettered entries so that nothing awaiting delivery or
+// reconciliation is lost when the job switches backends.
+func (s *migrateService) migrateOutbox(report *MigrationReport, dryRun bool) error {
+	src, err := s.srcOutbox.List()
+	if err != nil {
+		return fmt.Errorf("read source outbox: %w", err)
+	}
+
+	report.OutboxRead = len(src)
+	for _, e := range src {
+		cur, ok, err := s.dstOutbox.Get(e.Video.VideoID, e.Category)
+		if err != nil {
+			return fmt.Errorf("read target outbox: %w", err)
+		}
+		if ok {
+			if sameOutbox(cur, e) {
+				report.OutboxSkipped++
+			} else {
+				report.OutboxConflicts = append(report.OutboxConflicts,
+					fmt.Sprintf("outbox %s/%s: source state=%s attempts=%d target state=%s attempts=%d",
+						e.Video.VideoID, e.Category, e.State, e.Attempts, cur.State, cur.Attempts))
+			}
+			continue
+		}
+		if !dryRun {
+			if err := s.dstOutbox.Save(e); err != nil {
+				return fmt.Errorf("write outbox %s/%s: %w", e.Video.VideoID, e.Category, err)
+			}
+		}
+		report.OutboxCopied++
+	}
+	return nil
+}
+
+// verify re-reads the target and checks that every source row made it across.
+func (s *migrateService) verify(report *MigrationReport) error {
+	channels, err := s.dstChannels.ListAll()
+	if err != nil {
+		return fmt.Errorf("verify channels: %w", err)
+	}
+	history, err := s.dstNotified.ListAll()
+	if err != nil {
+		return fmt.Errorf("verify history: %w", err)
+	}
+	report.ChannelsInTarget = len(channels)
+	report.NotifiedInTarget = len(history)
+
+	wantChannels := report.ChannelsCopied + report.ChannelsSkipped + len(report.ChannelConflicts)
+	if report.ChannelsInTarget < wantChannels {
+		return fmt.Errorf("verify channels: target has %d rows, expected at least %d", report.ChannelsInTarget, wantChannels)
+	}
+	wantNotified := report.NotifiedCopied + report.NotifiedSkipped + len(report.NotifiedConflicts)
+	if report.NotifiedInTarget < wantNotified {
+		return fmt.Errorf("verify history: target has %d rows, expected at least %d", report.NotifiedInTarget, wantNotified)
+	}
+
+	src, err := s.srcNotified.ListAll()
+	if err != nil {
+		return fmt.Errorf("verify history: %w", err)
+	}
+	missing := 0
+	for _, rec := range src {
+		ok, err := s.dstNotified.Has(rec.VideoID)
+		if err != nil {
+			return fmt.Errorf("verify history: %w", err)
+		}
+		if !ok {
+			missing++
+			log.Printf("video %s is missing from the target after migration", rec.VideoID)
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("verify history: %d video(s) missing from the target", missing)
+	}
+	return s.verifyOutbox(report)
+}
+
+func (s *migrateService) verifyOutbox(report *MigrationReport) error {
+	entries, err := s.dstOutbox.List()
+	if err != nil {
+		return fmt.Errorf("verify outbox: %w", err)
+	}
+	report.OutboxInTarget = len(entries)
+	wantOutbox := report.OutboxCopied + report.OutboxSkipped + len(report.OutboxConflicts)
+	if report.OutboxInTarget < wantOutbox {
+		return fmt.Errorf("verify outbox: target has %d entries, expected at least %d", report.OutboxInTarget, wantOutbox)
+	}
+
+	src, err := s.srcOutbox.List()
+	if err != nil {
+		return fmt.Errorf("verify outbox: %w", err)
+	}
+	missing := 0
+	for _, e := range src {
+		_, ok, err := s.dstOutbox.Get(e.Video.VideoID, e.Category)
+		if err != nil {
+			return fmt.Errorf("verify outbox: %w", err)
+		}
+		if !ok {
+			missing++
+			log.Printf("outbox entry %s/%s is missing from the target after migration", e.Video.VideoID, e.Category)
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("verify outbox: %d entry(ies) missing from the target", missing)
+	}
+	return nil
+}
+
+// sameOutbox compares the delivery state; timestamps at second precision like sameNotified.
+func sameOutbox(a, b model.OutboxEntry) bool {
+	return a.State == b.State && a.Attempts == b.Attempts && a.Failures == b.Failures &&
+		a.NextRetryAt.Truncate(time.Second).Equal(b.NextRetryAt.Truncate(time.Second))
+}
+
+// sameNotified compares at second precision, which is what the CSV and SQLite stores keep.
+func sameNotified(a, b model.NotifiedRecord) bool {
+	return a.ChannelID == b.ChannelID &&
+		a.PublishedAt.Truncate(time.Second).Equal(b.PublishedAt.Truncate(time.Second))
+}
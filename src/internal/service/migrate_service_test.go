@@ -0,0 +1,99 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+func TestMigrateReportsConflictsAndVerifies(t *testing.T) {
+	dir := t.TempDir()
+	srcCh := &repository.CSVChannelRepository{Path: filepath.Join(dir, "channels.csv")}
+	srcNoti, err := repository.NewCSVNotifiedRepository(filepath.Join(dir, "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstCh := &repository.JSONLChannelRepository{Path: filepath.Join(dir, "channels.jsonl")}
+	dstNoti, err := repository.NewJSONLNotifiedRepository(filepath.Join(dir, "notified.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcOutbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "src-outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstOutbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "dst-outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, ch := range []model.ChannelDTO{
+		{ChannelID: "UC1", Category: "tech_en", Name: "One", Enabled: true},
+		{ChannelID: "UC2", Category: "news_jp", Name: "Two"},
+	} {
+		if err := srcCh.Add(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dstCh.Add(model.ChannelDTO{ChannelID: "UC2", Category: "news_en", Name: "Two"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"V1", "V2", "V3"} {
+		if err := srcNoti.Append(model.NotifiedRecord{VideoID: id, ChannelID: "UC1", PublishedAt: ts, NotifiedAt: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dstNoti.Append(model.NotifiedRecord{VideoID: "V1", ChannelID: "UC1", PublishedAt: ts, NotifiedAt: ts}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dstNoti.Append(model.NotifiedRecord{VideoID: "V2", ChannelID: "UC9", PublishedAt: ts, NotifiedAt: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := model.OutboxEntry{Category: "tech_en", Video: model.VideoDTO{VideoID: "V4", ChannelID: "UC1"}, State: model.OutboxPending, UpdatedAt: ts}
+	parked := model.OutboxEntry{Category: "tech_en", Video: model.VideoDTO{VideoID: "V5", ChannelID: "UC1"}, State: model.OutboxFailed, Attempts: 3, Failures: 4, UpdatedAt: ts}
+	for _, e := range []model.OutboxEntry{pending, parked} {
+		if err := srcOutbox.Save(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dstOutbox.Save(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewMigrateService(srcCh, srcNoti, srcOutbox, dstCh, dstNoti, dstOutbox)
+	dry, err := svc.Migrate(true)
+	if err != nil {
+		t.Fatalf("dry run error: %v", err)
+	}
+	if dry.ChannelsCopied != 1 || dry.NotifiedCopied != 1 || dry.OutboxCopied != 1 {
+		t.Fatalf("unexpected dry run report %+v", dry)
+	}
+	if ok, _ := dstNoti.Has("V3"); ok {
+		t.Fatalf("dry run must not write")
+	}
+
+	report, err := svc.Migrate(false)
+	if err != nil {
+		t.Fatalf("Migrate error: %v", err)
+	}
+	if report.ChannelsCopied != 1 || len(report.ChannelConflicts) != 1 {
+		t.Fatalf("unexpected channel report %+v", report)
+	}
+	if report.NotifiedCopied != 1 || report.NotifiedSkipped != 1 || len(report.NotifiedConflicts) != 1 {
+		t.Fatalf("unexpected history report %+v", report)
+	}
+	if report.OutboxCopied != 1 || report.OutboxSkipped != 1 || len(report.OutboxConflicts) != 0 {
+		t.Fatalf("unexpected outbox report %+v", report)
+	}
+	if report.ChannelsInTarget != 2 || report.NotifiedInTarget != 3 || report.OutboxInTarget != 2 {
+		t.Fatalf("unexpected target counts %+v", report)
+	}
+	if e, ok, _ := dstOutbox.Get("V5", "tech_en"); !ok || e.State != model.OutboxFailed || e.Failures != 4 || !e.NextRetryAt.IsZero() {
+		t.Fatalf("dead-lettered entry not migrated: %+v, %v", e, ok)
+	}
+}
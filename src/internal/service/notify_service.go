@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"sync"
@@ -15,62 +16,280 @@ import (
 
 type NotifyService interface {
 	Notify(category string, v model.VideoDTO) error
+	Reconcile() error
 	Stats() NotificationStats
+
+	// Dead-letter queue: failed notifications kept in the outbox for redelivery.
+	ListDeadLetters() ([]model.OutboxEntry, error)
+	RetryDeadLetter(videoID string) error
+	DropDeadLetter(videoID string) error
 }
 
+// Redelivery schedule for dead letters: the delay doubles after every failed run, and after
+// deadLetterMaxFailures the entry stays in the queue until it is retried or dropped by hand.
+const (
+	deadLetterBaseDelay   = 30 * time.Minute
+	deadLetterMaxDelay    = 24 * time.Hour
+	deadLetterMaxFailures = 8
+)
+
 type NotificationStats struct {
 	Sent            int
 	Failed          int
 	RetriedMessages int
 	RetryAttempts   int
+	Reconciled      int // sent earlier but recorded only now
+	Redelivered     int // pending entries left behind by an interrupted run
+	DeadLettered    int // failures moved to the dead-letter queue
+	DeadLetterSent  int // dead letters delivered on a later attempt
+}
+
+// Destination is where a category's notifications go: an output type (discord or slack) and its webhook URL.
+type Destination struct {
+	Output  string
+	Webhook string
 }
 
 type notifyService struct {
-	notifiedRepo      repository.NotifiedRepository
-	categoryToWebhook map[string]string
-	postSleep         time.Duration
+	notifiedRepo          repository.NotifiedRepository
+	outbox                repository.OutboxRepository
+	categoryToDestination map[string]Destination
+	postSleep             time.Duration
+	client                *http.Client
 
 	mu          sync.Mutex
 	dispatchers map[string]*webhookDispatcher
 	stats       NotificationStats
 }
 
-func NewNotifyService(notified repository.NotifiedRepository, categoryToWebhook map[string]string, postSleep time.Duration) NotifyService {
+func NewNotifyService(notified repository.NotifiedRepository, outbox repository.OutboxRepository, categoryToDestination map[string]Destination, postSleep time.Duration, client *http.Client) NotifyService {
 	return &notifyService{
-		notifiedRepo:      notified,
-		categoryToWebhook: categoryToWebhook,
-		postSleep:         postSleep,
-		dispatchers:       map[string]*webhookDispatcher{},
+		notifiedRepo:          notified,
+		outbox:                outbox,
+		categoryToDestination: categoryToDestination,
+		postSleep:             postSleep,
+		client:                client,
+		dispatchers:           map[string]*webhookDispatcher{},
 	}
 }
 
+// Notify delivers v through the outbox: the entry is written as pending before dispatch, marked
+// sent once the webhook accepted it, and removed after the history is recorded. A crash at any
+// point leaves an entry that Reconcile resolves without losing or re-posting the notification;
+// only a crash during the webhook request itself can still produce a duplicate.
 func (s *notifyService) Notify(category string, v model.VideoDTO) error {
-	webhook, ok := s.categoryToWebhook[strings.ToLower(category)]
-	if webhook == "" {
+	entry, ok, err := s.outbox.Get(v.VideoID, category)
+	if err != nil {
+		return fmt.Errorf("read outbox video=%s: %w", v.VideoID, err)
+	}
+	if ok && entry.State == model.OutboxSent {
+		return s.complete(entry)
+	}
+	if ok && entry.State == model.OutboxFailed {
+		if entry.NextRetryAt.IsZero() {
+			log.Printf("video=%s category=%s is parked in the dead-letter queue; retry or drop it by hand", v.VideoID, category)
+			return nil
+		}
+		if time.Now().Before(entry.NextRetryAt) {
+			log.Printf("video=%s category=%s is in the dead-letter queue until %s", v.VideoID, category, entry.NextRetryAt.Format(time.RFC3339))
+			return nil
+		}
+	}
+	if !ok {
+		entry = model.OutboxEntry{Category: category}
+	}
+	entry.Video = v
+	return s.deliver(entry)
+}
+
+// Reconcile resolves entries left behind by an earlier run: sent ones are recorded in the
+// history, pending ones are delivered again, and dead letters whose retry time has come are
+// redelivered even if the video has dropped out of the feed.
+func (s *notifyService) Reconcile() error {
+	entries, err := s.outbox.List()
+	if err != nil {
+		return fmt.Errorf("read outbox: %w", err)
+	}
+	for _, entry := range entries {
+		switch entry.State {
+		case model.OutboxSent:
+			if err := s.complete(entry); err != nil {
+				log.Printf("failed to reconcile video=%s category=%s: %v", entry.Video.VideoID, entry.Category, err)
+				continue
+			}
+			s.recordReconciled()
+		case model.OutboxPending:
+			s.recordRedelivered()
+			if err := s.deliver(entry); err != nil {
+				log.Printf("failed to redeliver video=%s category=%s: %v", entry.Video.VideoID, entry.Category, err)
+			}
+		case model.OutboxFailed:
+			if entry.NextRetryAt.IsZero() || time.Now().Before(entry.NextRetryAt) {
+				continue
+			}
+			if err := s.deliver(entry); err != nil {
+				log.Printf("dead letter video=%s category=%s failed again: %v", entry.Video.VideoID, entry.Category, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *notifyService) ListDeadLetters() ([]model.OutboxEntry, error) {
+	entries, err := s.outbox.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []model.OutboxEntry
+	for _, e := range entries {
+		if e.State == model.OutboxFailed {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// RetryDeadLetter redelivers the dead letters of videoID right away, ignoring their schedule.
+func (s *notifyService) RetryDeadLetter(videoID string) error {
+	entries, err := s.deadLettersFor(videoID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if err := s.deliver(e); err != nil {
+			errs = append(errs, fmt.Errorf("category=%s: %w", e.Category, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DropDeadLetter gives up on videoID: the entries leave the queue and the video is recorded
+// as seen so the feed does not bring it back.
+func (s *notifyService) DropDeadLetter(videoID string) error {
+	entries, err := s.deadLettersFor(videoID)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		v := e.Video
+		rec := model.NotifiedRecord{VideoID: v.VideoID, ChannelID: v.ChannelID, PublishedAt: v.PublishedAt, NotifiedAt: time.Now(), Category: e.Category}
+		if err := s.notifiedRepo.Append(rec); err != nil {
+			return fmt.Errorf("record dropped video=%s: %w", v.VideoID, err)
+		}
+		if err := s.outbox.Remove(v.VideoID, e.Category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *notifyService) deadLettersFor(videoID string) ([]model.OutboxEntry, error) {
+	all, err := s.ListDeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	var out []model.OutboxEntry
+	for _, e := range all {
+		if e.Video.VideoID == videoID {
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no dead letter for video=%s", videoID)
+	}
+	return out, nil
+}
+
+// nextRetryAt schedules the next automatic redelivery after the given number of failed runs.
+func nextRetryAt(now time.Time, failures int) time.Time {
+	if failures >= deadLetterMaxFailures {
+		return time.Time{}
+	}
+	delay := deadLetterBaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= deadLetterMaxDelay {
+			delay = deadLetterMaxDelay
+			break
+		}
+	}
+	return now.Add(delay)
+}
+
+func (s *notifyService) deliver(entry model.OutboxEntry) error {
+	v := entry.Video
+	dest, ok := s.categoryToDestination[strings.ToLower(entry.Category)]
+	if dest.Webhook == "" {
 		if ok {
-			return fmt.Errorf("webhook is empty for category=%s", category)
+			return fmt.Errorf("webhook is empty for category=%s", entry.Category)
 		}
-		return fmt.Errorf("webhook not mapped for category=%s", category)
+		return fmt.Errorf("webhook not mapped for category=%s", entry.Category)
 	}
 
-	dispatcher := s.dispatcherFor(webhook)
-	thumb := fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", v.VideoID)
-	content := notifier.NotificationContent{
-		Title:    v.Title,
-		Message:  fmt.Sprintf("%s | %s", v.ChannelName, v.PublishedAt.Format(time.RFC3339)),
-		URL:      v.Link,
-		ThumbURL: thumb,
+	dispatcher, err := s.dispatcherFor(dest)
+	if err != nil {
+		return fmt.Errorf("category=%s: %w", entry.Category, err)
+	}
+
+	wasDeadLetter := entry.State == model.OutboxFailed
+	entry.State = model.OutboxPending
+	entry.UpdatedAt = time.Now()
+	if err := s.outbox.Save(entry); err != nil {
+		return fmt.Errorf("write outbox video=%s: %w", v.VideoID, err)
 	}
 
-	retries, err := dispatcher.send(content)
+	retries, err := dispatcher.send(notificationContent(v))
+	entry.Attempts += retries
+	entry.UpdatedAt = time.Now()
 	if err != nil {
 		s.recordFailure()
+		entry.State = model.OutboxFailed
+		entry.LastError = err.Error()
+		entry.Failures++
+		entry.NextRetryAt = nextRetryAt(entry.UpdatedAt, entry.Failures)
+		if saveErr := s.outbox.Save(entry); saveErr != nil {
+			log.Printf("failed to move video=%s to the dead-letter queue: %v", v.VideoID, saveErr)
+		} else if !wasDeadLetter {
+			s.recordDeadLettered()
+		}
 		return err
 	}
 
 	s.recordSuccess(retries)
-	_ = s.notifiedRepo.Append(v.VideoID, v.ChannelID, v.PublishedAt, time.Now())
-	return nil
+	if wasDeadLetter {
+		s.recordDeadLetterSent()
+	}
+	entry.Attempts++
+	entry.State = model.OutboxSent
+	entry.LastError = ""
+	if err := s.outbox.Save(entry); err != nil {
+		// The entry stays pending, so the next run posts it again; there is nothing safer to do here.
+		return fmt.Errorf("mark outbox entry sent video=%s: %w", v.VideoID, err)
+	}
+	return s.complete(entry)
+}
+
+// complete records a delivered entry in the history and drops it from the outbox.
+func (s *notifyService) complete(entry model.OutboxEntry) error {
+	v := entry.Video
+	rec := model.NotifiedRecord{VideoID: v.VideoID, ChannelID: v.ChannelID, PublishedAt: v.PublishedAt, NotifiedAt: entry.UpdatedAt, Category: entry.Category}
+	if err := s.notifiedRepo.Append(rec); err != nil {
+		return fmt.Errorf("record notified video=%s: %w", v.VideoID, err)
+	}
+	return s.outbox.Remove(v.VideoID, entry.Category)
+}
+
+func notificationContent(v model.VideoDTO) notifier.NotificationContent {
+	thumb := fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", v.VideoID)
+	return notifier.NotificationContent{
+		Title:       v.Title,
+		Message:     fmt.Sprintf("%s | %s", v.ChannelName, v.PublishedAt.Format(time.RFC3339)),
+		URL:         v.Link,
+		ThumbURL:    thumb,
+		ChannelName: v.ChannelName,
+		PublishedAt: v.PublishedAt,
+	}
 }
 
 func (s *notifyService) Stats() NotificationStats {
@@ -79,25 +298,30 @@ func (s *notifyService) Stats() NotificationStats {
 	return s.stats
 }
 
-func (s *notifyService) dispatcherFor(webhook string) *webhookDispatcher {
+func (s *notifyService) dispatcherFor(dest Destination) (*webhookDispatcher, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	dispatcher, ok := s.dispatchers[webhook]
+	dispatcher, ok := s.dispatchers[dest.Webhook]
 	if ok {
-		return dispatcher
+		return dispatcher, nil
+	}
+	n, err := notifier.New(dest.Output, dest.Webhook, s.client)
+	if err != nil {
+		return nil, err
 	}
 	minInterval := time.Second
 	if s.postSleep > minInterval {
 		minInterval = s.postSleep
 	}
 	dispatcher = &webhookDispatcher{
-		notifier:    &notifier.DiscordNotifier{Webhook: webhook},
+		notifier:    n,
+		output:      strings.ToLower(dest.Output),
 		minInterval: minInterval,
 		maxRetries:  5,
 		baseBackoff: 2 * time.Second,
 	}
-	s.dispatchers[webhook] = dispatcher
-	return dispatcher
+	s.dispatchers[dest.Webhook] = dispatcher
+	return dispatcher, nil
 }
 
 func (s *notifyService) recordSuccess(retries int) {
@@ -110,6 +334,30 @@ func (s *notifyService) recordSuccess(retries int) {
 	}
 }
 
+func (s *notifyService) recordReconciled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Reconciled++
+}
+
+func (s *notifyService) recordRedelivered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Redelivered++
+}
+
+func (s *notifyService) recordDeadLettered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.DeadLettered++
+}
+
+func (s *notifyService) recordDeadLetterSent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.DeadLetterSent++
+}
+
 func (s *notifyService) recordFailure() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -118,6 +366,7 @@ func (s *notifyService) recordFailure() {
 
 type webhookDispatcher struct {
 	notifier    notifier.Notifier
+	output      string
 	minInterval time.Duration
 	maxRetries  int
 	baseBackoff time.Duration
@@ -150,7 +399,7 @@ func (d *webhookDispatcher) send(content notifier.NotificationContent) (int, err
 
 		retries++
 
-		if httpErr := asDiscordHTTPError(lastErr); httpErr != nil {
+		if httpErr := asWebhookHTTPError(lastErr); httpErr != nil {
 			wait := httpErr.RetryAfter
 			if wait <= 0 {
 				wait = backoff
@@ -175,13 +424,23 @@ func (d *webhookDispatcher) send(content notifier.NotificationContent) (int, err
 			break
 		}
 	}
-	return retries, fmt.Errorf("failed to send discord notification after %d attempts: %w", d.maxRetries, lastErr)
+	return retries, fmt.Errorf("failed to send %s notification after %d attempts: %w", d.output, d.maxRetries, lastErr)
 }
 
-func asDiscordHTTPError(err error) *notifier.DiscordHTTPError {
-	var httpErr *notifier.DiscordHTTPError
-	if errors.As(err, &httpErr) {
-		return httpErr
+// webhookHTTPError is the status and Retry-After hint shared by the Discord and Slack webhook errors.
+type webhookHTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func asWebhookHTTPError(err error) *webhookHTTPError {
+	var discordErr *notifier.DiscordHTTPError
+	if errors.As(err, &discordErr) {
+		return &webhookHTTPError{StatusCode: discordErr.StatusCode, RetryAfter: discordErr.RetryAfter}
+	}
+	var slackErr *notifier.SlackHTTPError
+	if errors.As(err, &slackErr) {
+		return &webhookHTTPError{StatusCode: slackErr.StatusCode, RetryAfter: slackErr.RetryAfter}
 	}
 	return nil
 }
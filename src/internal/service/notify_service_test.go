@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/notifier"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+func TestNotifyReconcilesOutbox(t *testing.T) {
+	var posts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(dir, "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	sent := model.VideoDTO{VideoID: "SENT", ChannelID: "UC1", Title: "delivered before the crash", PublishedAt: now}
+	pending := model.VideoDTO{VideoID: "PENDING", ChannelID: "UC1", Title: "interrupted", PublishedAt: now}
+	if err := outbox.Save(model.OutboxEntry{Category: "tech_en", Video: sent, State: model.OutboxSent, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Save(model.OutboxEntry{Category: "tech_en", Video: pending, State: model.OutboxPending, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewNotifyService(notified, outbox, map[string]Destination{
+		"tech_en": {Output: "discord", Webhook: srv.URL},
+	}, 0, nil)
+	if err := svc.Reconcile(); err != nil {
+		t.Fatalf("Reconcile error: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&posts); got != 1 {
+		t.Fatalf("expected only the pending entry to be posted, got %d posts", got)
+	}
+	for _, id := range []string{"SENT", "PENDING"} {
+		if ok, _ := notified.Has(id); !ok {
+			t.Fatalf("expected %s to be recorded", id)
+		}
+	}
+	entries, err := outbox.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected an empty outbox, got %+v", entries)
+	}
+	if stats := svc.Stats(); stats.Reconciled != 1 || stats.Redelivered != 1 || stats.Sent != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Reopening the log must see the removals.
+	reopened, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := reopened.List(); len(entries) != 0 {
+		t.Fatalf("expected compacted outbox to be empty, got %+v", entries)
+	}
+}
+
+func TestNotifyDeadLetters(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(dir, "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := Destination{Output: "discord", Webhook: srv.URL}
+	svc := NewNotifyService(notified, outbox, map[string]Destination{"tech_en": dest}, 0, nil)
+	dispatcher, err := svc.(*notifyService).dispatcherFor(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.maxRetries = 1
+	dispatcher.baseBackoff = time.Millisecond
+	dispatcher.minInterval = 0
+
+	v := model.VideoDTO{VideoID: "VIDEO1", ChannelID: "UC1", Title: "flaky", PublishedAt: time.Now()}
+	if err := svc.Notify("tech_en", v); err == nil {
+		t.Fatalf("expected the first delivery to fail")
+	}
+	letters, err := svc.ListDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Failures != 1 || letters[0].LastError == "" || letters[0].NextRetryAt.IsZero() {
+		t.Fatalf("unexpected dead letters %+v", letters)
+	}
+
+	// Not due yet: the feed bringing the video back must not trigger a post.
+	if err := svc.Notify("tech_en", v); err != nil {
+		t.Fatalf("Notify error: %v", err)
+	}
+	if stats := svc.Stats(); stats.Failed != 1 {
+		t.Fatalf("expected the scheduled dead letter to be skipped, got %+v", stats)
+	}
+
+	// After the last automatic attempt the entry is parked until it is handled by hand.
+	parked := letters[0]
+	parked.Failures = deadLetterMaxFailures
+	parked.NextRetryAt = time.Time{}
+	if err := outbox.Save(parked); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Notify("tech_en", v); err != nil {
+		t.Fatalf("Notify error: %v", err)
+	}
+	if err := svc.Reconcile(); err != nil {
+		t.Fatalf("Reconcile error: %v", err)
+	}
+	if stats := svc.Stats(); stats.Failed != 1 {
+		t.Fatalf("expected the parked dead letter to be skipped, got %+v", stats)
+	}
+
+	fail.Store(false)
+	if err := svc.RetryDeadLetter("VIDEO1"); err != nil {
+		t.Fatalf("RetryDeadLetter error: %v", err)
+	}
+	if ok, _ := notified.Has("VIDEO1"); !ok {
+		t.Fatalf("expected the retried video to be recorded")
+	}
+	if letters, _ := svc.ListDeadLetters(); len(letters) != 0 {
+		t.Fatalf("expected an empty dead-letter queue, got %+v", letters)
+	}
+	if stats := svc.Stats(); stats.DeadLettered != 1 || stats.DeadLetterSent != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestNextRetryAt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := nextRetryAt(now, 1); !got.Equal(now.Add(deadLetterBaseDelay)) {
+		t.Fatalf("first retry at %s", got)
+	}
+	if got := nextRetryAt(now, 3); !got.Equal(now.Add(4 * deadLetterBaseDelay)) {
+		t.Fatalf("third retry at %s", got)
+	}
+	if got := nextRetryAt(now, deadLetterMaxFailures-1); !got.Equal(now.Add(deadLetterMaxDelay)) {
+		t.Fatalf("retry delay should be capped, got %s", got)
+	}
+	if got := nextRetryAt(now, deadLetterMaxFailures); !got.IsZero() {
+		t.Fatalf("expected automatic retries to stop, got %s", got)
+	}
+}
+
+func TestSlackRateLimitIsAWebhookHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	n, err := notifier.New(notifier.OutputSlack, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendErr := n.Send(notifier.NotificationContent{Title: "t", URL: "https://example.test"})
+	httpErr := asWebhookHTTPError(fmt.Errorf("wrapped: %w", sendErr))
+	if httpErr == nil || httpErr.StatusCode != http.StatusTooManyRequests || httpErr.RetryAfter != 3*time.Second {
+		t.Fatalf("asWebhookHTTPError(%v) = %+v", sendErr, httpErr)
+	}
+}
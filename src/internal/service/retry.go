@@ -0,0 +1,78 @@
+package service
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+// RetryPolicy bounds retries of transient fetch errors. The n-th retry waits a jittered
+// BaseDelay*2^n capped at MaxDelay, or the server's Retry-After when that is longer.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// delay returns the wait before retry number attempt (0-based), or false when the server
+// asked for a pause longer than MaxDelay and the retry should be abandoned.
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration, jitter func(time.Duration) time.Duration) (time.Duration, bool) {
+	backoff := p.BaseDelay << attempt
+	if attempt >= 32 || backoff > p.MaxDelay || backoff <= 0 {
+		backoff = p.MaxDelay
+	}
+	// Equal jitter: keep half of the backoff and randomise the rest so workers do not retry in lockstep.
+	wait := backoff/2 + jitter(backoff-backoff/2)
+	if retryAfter > 0 {
+		if retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+	return wait, true
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max + 1)
+}
+
+// withRetry runs fn until it succeeds, fails permanently or the retry budget is spent.
+func (s *feedService) withRetry(op, channelID string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !repository.IsTransient(err) {
+			return err
+		}
+		if attempt >= s.retry.MaxRetries {
+			if s.retry.MaxRetries > 0 {
+				s.recordRetryExhausted()
+			}
+			return err
+		}
+		wait, ok := s.retry.delay(attempt, repository.RetryAfterOf(err), s.jitter)
+		if !ok {
+			log.Printf("%s failed for channel=%s: %v; retry-after exceeds %s, giving up", op, channelID, err, s.retry.MaxDelay)
+			s.recordRetryExhausted()
+			return err
+		}
+		log.Printf("%s failed for channel=%s (attempt %d/%d): %v; retrying in %s", op, channelID, attempt+1, s.retry.MaxRetries+1, err, wait)
+		s.recordRetry()
+		s.sleep(wait)
+	}
+}
+
+// withAPIRetry retries a whole YouTube call only when the repository does not already retry its
+// individual requests; retrying both would repeat the pages that had succeeded.
+func (s *feedService) withAPIRetry(op, channelID string, fn func() error) error {
+	if s.ytRetries {
+		return fn()
+	}
+	return s.withRetry(op, channelID, fn)
+}
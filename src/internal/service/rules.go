@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+)
+
+// Rule matches one field of a video, either by case-insensitive keyword or by regular expression.
+//
+// Rules are written as "field:keyword" or "field~regex" and separated by ';' in a rule list,
+// e.g. "title:sponsored; description~(?i)#PR\b; channel:clips". field is title, description,
+// channel (the channel name) or text (title or description); a bare "keyword" means text.
+type Rule struct {
+	Field   string
+	Keyword string         // lowercased; empty for regex rules
+	Pattern *regexp.Regexp // nil for keyword rules
+	Source  string         // the rule as written, for logs
+}
+
+var ruleFields = map[string]bool{"title": true, "description": true, "channel": true, "text": true}
+
+func ParseRules(list string) ([]Rule, error) {
+	var rules []Rule
+	for _, raw := range strings.Split(list, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		rule, err := parseRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(raw string) (Rule, error) {
+	idx := strings.IndexAny(raw, ":~")
+	if idx == -1 || !ruleFields[strings.ToLower(strings.TrimSpace(raw[:idx]))] {
+		return Rule{Field: "text", Keyword: strings.ToLower(raw), Source: raw}, nil
+	}
+	rule := Rule{Field: strings.ToLower(strings.TrimSpace(raw[:idx])), Source: raw}
+	value := strings.TrimSpace(raw[idx+1:])
+	if value == "" {
+		return Rule{}, fmt.Errorf("rule %q: empty pattern", raw)
+	}
+	if raw[idx] == ':' {
+		rule.Keyword = strings.ToLower(value)
+		return rule, nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %w", raw, err)
+	}
+	rule.Pattern = re
+	return rule, nil
+}
+
+// Match reports whether the rule matches v; channelName stands in when the feed omits it.
+func (r Rule) Match(v model.VideoDTO, channelName string) bool {
+	var fields []string
+	switch r.Field {
+	case "title":
+		fields = []string{v.Title}
+	case "description":
+		fields = []string{v.Description}
+	case "channel":
+		if v.ChannelName != "" {
+			channelName = v.ChannelName
+		}
+		fields = []string{channelName}
+	default:
+		fields = []string{v.Title, v.Description}
+	}
+	for _, f := range fields {
+		if r.Pattern != nil && r.Pattern.MatchString(f) {
+			return true
+		}
+		if r.Pattern == nil && strings.Contains(strings.ToLower(f), r.Keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleSet filters the videos of a category or channel. A video matching any Exclude rule is
+// dropped; when Include is not empty a video must also match one of its rules.
+type RuleSet struct {
+	Include []Rule
+	Exclude []Rule
+}
+
+func (rs RuleSet) IsZero() bool {
+	return len(rs.Include) == 0 && len(rs.Exclude) == 0
+}
+
+// Decide returns whether v is kept and a description of the rule that decided it.
+func (rs RuleSet) Decide(v model.VideoDTO, channelName string) (keep bool, reason string) {
+	for _, r := range rs.Exclude {
+		if r.Match(v, channelName) {
+			return false, "exclude " + r.Source
+		}
+	}
+	if len(rs.Include) == 0 {
+		return true, "no include rules"
+	}
+	for _, r := range rs.Include {
+		if r.Match(v, channelName) {
+			return true, "include " + r.Source
+		}
+	}
+	return false, "no include rule matched"
+}
+
+// merge adds a channel's rules to its category's: excludes add up, and the channel's includes
+// replace the category's when it has any.
+func (rs RuleSet) merge(channel RuleSet) RuleSet {
+	out := RuleSet{Include: rs.Include, Exclude: append(append([]Rule(nil), rs.Exclude...), channel.Exclude...)}
+	if len(channel.Include) > 0 {
+		out.Include = channel.Include
+	}
+	return out
+}
+
+// ParseRuleSet parses an include and an exclude rule list.
+func ParseRuleSet(include, exclude string) (RuleSet, error) {
+	in, err := ParseRules(include)
+	if err != nil {
+		return RuleSet{}, fmt.Errorf("include: %w", err)
+	}
+	ex, err := ParseRules(exclude)
+	if err != nil {
+		return RuleSet{}, fmt.Errorf("exclude: %w", err)
+	}
+	return RuleSet{Include: in, Exclude: ex}, nil
+}
+
+// Route sends a video matching Rule to Category instead of its channel's category.
+type Route struct {
+	Category string
+	Rule     Rule
+}
+
+// ParseRoutes parses "category=rule" entries separated by ';', e.g.
+// "vlog=title:vlog; tech_en=title~^[ -~]+$". The first matching route wins.
+func ParseRoutes(list string) ([]Route, error) {
+	var routes []Route
+	for _, raw := range strings.Split(list, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		category, rule, ok := strings.Cut(raw, "=")
+		category = strings.ToLower(strings.TrimSpace(category))
+		if !ok || category == "" {
+			return nil, fmt.Errorf("route %q: want category=rule", raw)
+		}
+		r, err := parseRule(strings.TrimSpace(rule))
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", raw, err)
+		}
+		routes = append(routes, Route{Category: category, Rule: r})
+	}
+	return routes, nil
+}
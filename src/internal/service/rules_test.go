@@ -0,0 +1,118 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+func TestRuleSetDecide(t *testing.T) {
+	category, err := ParseRuleSet("title:minecraft; title~^\\[LIVE\\]", "description:#PR; channel:clips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel, err := ParseRuleSet("", "sponsored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := category.merge(channel)
+
+	cases := []struct {
+		video model.VideoDTO
+		keep  bool
+		rule  string
+	}{
+		{model.VideoDTO{Title: "MINECRAFT hardcore #3"}, true, `include title:minecraft`},
+		{model.VideoDTO{Title: "[LIVE] speedrun"}, true, `include title~^\[LIVE\]`},
+		{model.VideoDTO{Title: "[live] speedrun"}, false, "no include rule matched"},
+		{model.VideoDTO{Title: "Minecraft", Description: "Thanks to our SPONSORED partner"}, false, `exclude sponsored`},
+		{model.VideoDTO{Title: "Minecraft", Description: "#pr"}, false, `exclude description:#PR`},
+		{model.VideoDTO{Title: "Minecraft", ChannelName: "Best Clips"}, false, `exclude channel:clips`},
+	}
+	for _, c := range cases {
+		keep, rule := rules.Decide(c.video, "")
+		if keep != c.keep || rule != c.rule {
+			t.Errorf("%+v: got keep=%v rule=%s, want keep=%v rule=%s", c.video, keep, rule, c.keep, c.rule)
+		}
+	}
+
+	if _, err := ParseRules("title~(unclosed"); err == nil {
+		t.Fatal("an invalid regex should be rejected")
+	}
+}
+
+func TestFeedAppliesChannelRules(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		{VideoID: "KEEP", ChannelID: "UC1", Title: "Full episode"},
+		{VideoID: "CLIP", ChannelID: "UC1", Title: "Funny moments (clip)"},
+	}}
+	svc := NewFeedService(rss, nil, notified, nil, FeedOptions{FirstRun: FirstRunNotifyAll})
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", FetchLimit: 5, ExcludeRules: "title:clip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || videos[0].VideoID != "KEEP" {
+		t.Fatalf("expected only KEEP, got %+v", videos)
+	}
+	if seen, _ := notified.Has("CLIP"); !seen {
+		t.Fatal("an excluded video should be marked as seen")
+	}
+	if stats := svc.Stats(); stats.RuleExcluded != 1 {
+		t.Fatalf("expected 1 rule exclusion, got %+v", stats)
+	}
+}
+
+func TestFeedRoutesVideosByTitle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notified.csv")
+	notified, err := repository.NewCSVNotifiedRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := ParseRoutes("vlog=title:vlog; english=title~^[ -~]+$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := &scriptedFeedRepository{videos: []model.VideoDTO{
+		{VideoID: "TECH", ChannelID: "UC1", Title: "Goの並行処理入門"},
+		{VideoID: "VLOG", ChannelID: "UC1", Title: "東京 vlog"},
+		{VideoID: "EN", ChannelID: "UC1", Title: "Intro to Go generics"},
+		{VideoID: "SHORT", ChannelID: "UC1", Title: "vlog", Kind: model.VideoKindShort},
+	}}
+	svc := NewFeedService(rss, nil, notified, nil, FeedOptions{
+		FirstRun:       FirstRunNotifyAll,
+		CategoryRoutes: map[string][]Route{"tech": routes},
+	})
+
+	videos, err := svc.ListNewVideos(model.ChannelDTO{ChannelID: "UC1", Category: "tech", FetchLimit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, v := range videos {
+		got[v.VideoID] = v.Category
+	}
+	want := map[string]string{"TECH": "tech", "VLOG": "vlog", "EN": "english"}
+	if len(got) != len(want) || got["TECH"] != want["TECH"] || got["VLOG"] != want["VLOG"] || got["EN"] != want["EN"] {
+		t.Fatalf("got categories %v, want %v", got, want)
+	}
+
+	// Videos recorded without notification keep the category they were routed to.
+	reloaded, err := repository.NewCSVNotifiedRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := reloaded.ListAll()
+	if len(records) != 1 || records[0].VideoID != "SHORT" || records[0].Category != "vlog" {
+		t.Fatalf("unexpected history %+v", records)
+	}
+	if _, err := ParseRoutes("title:vlog"); err == nil {
+		t.Fatal("a route without a category should be rejected")
+	}
+}
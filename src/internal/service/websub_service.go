@@ -0,0 +1,376 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+// ErrInvalidSignature is returned for pushed content whose X-Hub-Signature does not match the secret.
+var ErrInvalidSignature = errors.New("websub signature mismatch")
+
+// WebSubService keeps one hub subscription per enabled channel and turns pushed feeds into
+// notifications. Channels whose subscription is not active are reported by PollTargets so
+// the caller can keep polling them.
+type WebSubService interface {
+	// Sync subscribes new channels, renews leases close to expiry, retries failed
+	// subscriptions and unsubscribes channels that are no longer enabled.
+	Sync(channels []model.ChannelDTO)
+	// Verify answers a hub verification request; ok is false when it must be rejected.
+	Verify(mode, topic, challenge string, lease time.Duration) (string, bool)
+	// HandlePush verifies and processes a pushed feed. channelID is the hint from the callback URL.
+	HandlePush(channelID string, body []byte, signature string) error
+	PollTargets() []model.ChannelDTO
+}
+
+type WebSubOptions struct {
+	Callback      string // public URL of the callback; the channel is added as ?channel_id=
+	FeedBaseURL   string // base of the topic URLs, defaults to YouTube
+	Secret        string
+	Lease         time.Duration // requested lease; the hub may grant a different one
+	RenewBefore   time.Duration
+	VerifyTimeout time.Duration // a subscription not verified within this is treated as failed
+	RetryInterval time.Duration // wait before re-requesting a failed subscription
+	// PushMaxAge drops pushed entries published longer ago; zero disables the limit. The hub
+	// also pushes when an old video's title or description changes.
+	PushMaxAge time.Duration
+}
+
+type subscriptionState string
+
+const (
+	subscriptionPending subscriptionState = "pending"
+	subscriptionActive  subscriptionState = "active"
+	subscriptionFailed  subscriptionState = "failed"
+)
+
+type subscription struct {
+	channel     model.ChannelDTO
+	state       subscriptionState
+	requestedAt time.Time
+	expiresAt   time.Time
+	retryAt     time.Time
+}
+
+type websubService struct {
+	hub       repository.WebSubHub
+	feedSvc   FeedService
+	notifySvc NotifyService
+	notified  repository.NotifiedRepository
+	opts      WebSubOptions
+	now       func() time.Time
+
+	mu            sync.Mutex
+	subs          map[string]*subscription // by channel ID
+	unsubscribing map[string]bool
+}
+
+func NewWebSubService(hub repository.WebSubHub, fs FeedService, ns NotifyService, notified repository.NotifiedRepository, opts WebSubOptions) WebSubService {
+	return &websubService{hub: hub, feedSvc: fs, notifySvc: ns, notified: notified, opts: opts, now: time.Now,
+		subs: map[string]*subscription{}, unsubscribing: map[string]bool{}}
+}
+
+func (s *websubService) Sync(channels []model.ChannelDTO) {
+	now := s.now()
+	enabled := map[string]model.ChannelDTO{}
+	for _, ch := range channels {
+		enabled[ch.ChannelID] = ch
+	}
+
+	s.mu.Lock()
+	var subscribe, unsubscribe []string
+	for id, ch := range enabled {
+		sub, ok := s.subs[id]
+		if !ok {
+			sub = &subscription{channel: ch, state: subscriptionFailed}
+			s.subs[id] = sub
+		}
+		sub.channel = ch
+		switch sub.state {
+		case subscriptionActive:
+			if !now.Before(sub.expiresAt) {
+				log.Printf("websub lease expired for channel=%s; polling until resubscribed", id)
+				sub.state = subscriptionFailed
+				subscribe = append(subscribe, id)
+			} else if !now.Before(sub.expiresAt.Add(-s.opts.RenewBefore)) && now.Sub(sub.requestedAt) > s.opts.VerifyTimeout {
+				subscribe = append(subscribe, id)
+			}
+		case subscriptionPending:
+			if now.Sub(sub.requestedAt) > s.opts.VerifyTimeout {
+				log.Printf("websub subscription for channel=%s was not verified within %s; polling instead", id, s.opts.VerifyTimeout)
+				sub.state = subscriptionFailed
+				sub.retryAt = now.Add(s.opts.RetryInterval)
+			}
+		case subscriptionFailed:
+			if !now.Before(sub.retryAt) {
+				subscribe = append(subscribe, id)
+			}
+		}
+	}
+	for id := range s.subs {
+		if _, ok := enabled[id]; !ok {
+			unsubscribe = append(unsubscribe, id)
+			delete(s.subs, id)
+			s.unsubscribing[id] = true
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Strings(subscribe)
+	for _, id := range subscribe {
+		s.subscribe(id, now)
+	}
+	for _, id := range unsubscribe {
+		if err := s.hub.Unsubscribe(s.request(id)); err != nil {
+			log.Printf("websub unsubscribe failed for channel=%s: %v", id, err)
+		}
+	}
+}
+
+func (s *websubService) subscribe(channelID string, now time.Time) {
+	// The hub may send its verification before answering the request, so the subscription
+	// must already be pending when the request goes out. A renewal keeps the current lease
+	// active until the hub confirms the new one.
+	s.mu.Lock()
+	sub, ok := s.subs[channelID]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	sub.requestedAt = now
+	if sub.state != subscriptionActive {
+		sub.state = subscriptionPending
+	}
+	s.mu.Unlock()
+
+	err := s.hub.Subscribe(s.request(channelID))
+	if err == nil {
+		return
+	}
+	log.Printf("websub subscribe failed for channel=%s: %v; polling instead", channelID, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok = s.subs[channelID]; !ok {
+		return
+	}
+	sub.retryAt = now.Add(s.opts.RetryInterval)
+	if sub.state != subscriptionActive {
+		sub.state = subscriptionFailed
+	}
+}
+
+func (s *websubService) request(channelID string) repository.WebSubRequest {
+	return repository.WebSubRequest{
+		Topic:    repository.WebSubTopic(s.opts.FeedBaseURL, channelID),
+		Callback: callbackURL(s.opts.Callback, channelID),
+		Secret:   s.opts.Secret,
+		Lease:    s.opts.Lease,
+	}
+}
+
+func callbackURL(base, channelID string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "channel_id=" + url.QueryEscape(channelID)
+}
+
+func (s *websubService) Verify(mode, topic, challenge string, lease time.Duration) (string, bool) {
+	u, err := url.Parse(topic)
+	if err != nil || (challenge == "" && mode != "denied") {
+		return "", false
+	}
+	channelID := u.Query().Get("channel_id")
+	if topic != repository.WebSubTopic(s.opts.FeedBaseURL, channelID) {
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[channelID]
+	switch mode {
+	case "subscribe":
+		if !ok || sub.state == subscriptionFailed {
+			return "", false
+		}
+		if lease <= 0 {
+			lease = s.opts.Lease
+		}
+		sub.state = subscriptionActive
+		sub.expiresAt = s.now().Add(lease)
+		log.Printf("websub subscription verified for channel=%s (lease %s)", channelID, lease)
+		return challenge, true
+	case "unsubscribe":
+		if !s.unsubscribing[channelID] {
+			return "", false
+		}
+		delete(s.unsubscribing, channelID)
+		return challenge, true
+	case "denied":
+		if ok {
+			log.Printf("websub hub denied the subscription for channel=%s; polling instead", channelID)
+			sub.state = subscriptionFailed
+			sub.retryAt = s.now().Add(s.opts.RetryInterval)
+		}
+		return "", true
+	}
+	return "", false
+}
+
+func (s *websubService) HandlePush(channelID string, body []byte, signature string) error {
+	if s.opts.Secret != "" && !validSignature(s.opts.Secret, body, signature) {
+		return ErrInvalidSignature
+	}
+	pushed, err := repository.DecodePushedFeed(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("decode pushed feed: %w", err)
+	}
+
+	byChannel := map[string][]model.VideoDTO{}
+	for _, v := range pushed.Videos {
+		if v.ChannelID == "" {
+			v.ChannelID = channelID
+		}
+		byChannel[v.ChannelID] = append(byChannel[v.ChannelID], v)
+	}
+	for id, videos := range byChannel {
+		ch, ok := s.channel(id)
+		if !ok {
+			log.Printf("ignoring pushed videos for unsubscribed channel=%s", id)
+			continue
+		}
+		cutoff, err := s.pushCutoff(id)
+		if err != nil {
+			return err
+		}
+		recent := videos[:0]
+		for _, v := range videos {
+			if v.PublishedAt.Before(cutoff) {
+				log.Printf("ignoring pushed update of channel=%s video=%s published %s", id, v.VideoID, v.PublishedAt.Format(time.RFC3339))
+				continue
+			}
+			recent = append(recent, v)
+		}
+		fresh, err := s.feedSvc.FilterNew(ch, recent)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(fresh, func(a, b int) bool {
+			return fresh[a].PublishedAt.Before(fresh[b].PublishedAt)
+		})
+		for _, v := range fresh {
+			if err := s.notifySvc.Notify(v.Category, v); err != nil {
+				log.Printf("failed to notify channel=%s video=%s: %v", ch.ChannelID, v.VideoID, err)
+			}
+		}
+	}
+
+	for _, d := range pushed.Deleted {
+		if d.ChannelID == "" {
+			d.ChannelID = channelID
+		}
+		seen, err := s.notified.Has(d.VideoID)
+		if err != nil {
+			return err
+		}
+		if seen {
+			continue
+		}
+		log.Printf("video=%s of channel=%s was deleted; it will not be notified", d.VideoID, d.ChannelID)
+		// The zero publish time keeps the tombstone out of the channel's first-run check and
+		// upload cursor, which must only reflect videos that were actually fetched.
+		if err := s.notified.Append(model.NotifiedRecord{VideoID: d.VideoID, ChannelID: d.ChannelID, NotifiedAt: s.now()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushCutoff is the publish time before which a pushed entry is an edit of a video that was
+// already there, not an upload: PushMaxAge ago, or the channel's newest known video if later.
+func (s *websubService) pushCutoff(channelID string) (time.Time, error) {
+	var cutoff time.Time
+	if s.opts.PushMaxAge > 0 {
+		cutoff = s.now().Add(-s.opts.PushMaxAge)
+	}
+	latest, ok, err := s.notified.LatestForChannel(channelID)
+	if err != nil || !ok {
+		return cutoff, err
+	}
+	// As with the upload cursor, a scheduled stream's future publish time must not hide uploads.
+	at := latest.PublishedAt
+	if !latest.NotifiedAt.IsZero() && latest.NotifiedAt.Before(at) {
+		at = latest.NotifiedAt
+	}
+	if at.After(cutoff) {
+		cutoff = at
+	}
+	return cutoff, nil
+}
+
+func (s *websubService) channel(id string) (model.ChannelDTO, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return model.ChannelDTO{}, false
+	}
+	return sub.channel, true
+}
+
+func (s *websubService) PollTargets() []model.ChannelDTO {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []model.ChannelDTO
+	for _, sub := range s.subs {
+		if sub.state != subscriptionActive {
+			out = append(out, sub.channel)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ChannelID < out[j].ChannelID })
+	return out
+}
+
+// validSignature checks an X-Hub-Signature header ("sha1=<hex>", or sha256/384/512).
+func validSignature(secret string, body []byte, header string) bool {
+	algo, sig, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+	var h func() hash.Hash
+	switch strings.ToLower(algo) {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	want, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellomyzn/yt-notifier/internal/model"
+	"github.com/hellomyzn/yt-notifier/internal/repository"
+)
+
+const pushedFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:at="http://purl.org/atompub/tombstones/1.0" xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <id>yt:video:NEWVIDEO</id>
+    <yt:videoId>NEWVIDEO</yt:videoId>
+    <yt:channelId>UCaaaaaaaaaaaaaaaaaaaaaa</yt:channelId>
+    <title>Pushed upload</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=NEWVIDEO"/>
+    <author><name>Example</name><uri>https://www.youtube.com/channel/UCaaaaaaaaaaaaaaaaaaaaaa</uri></author>
+    <published>2024-05-01T10:00:00+00:00</published>
+  </entry>
+  <at:deleted-entry ref="yt:video:GONEVIDEO" when="2024-05-01T11:00:00.000000+00:00">
+    <link href="https://www.youtube.com/watch?v=GONEVIDEO"/>
+    <at:by><name>Example</name><uri>https://www.youtube.com/channel/UCaaaaaaaaaaaaaaaaaaaaaa</uri></at:by>
+  </at:deleted-entry>
+</feed>`
+
+func TestWebSubSubscribeVerifyAndPush(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []url.Values
+	)
+	hubSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		requests = append(requests, r.PostForm)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hubSrv.Close()
+	var posts int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	dir := t.TempDir()
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(dir, "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	feedSvc := NewFeedService(nil, nil, notified, nil, FeedOptions{IncludeShorts: true})
+	notifySvc := NewNotifyService(notified, outbox, map[string]Destination{"tech_en": {Output: "discord", Webhook: webhook.URL}}, 0, nil)
+	ws := NewWebSubService(&repository.HTTPWebSubHub{HubURL: hubSrv.URL}, feedSvc, notifySvc, notified, WebSubOptions{
+		Callback: "https://example.test/websub", Secret: "s3cret", Lease: time.Hour,
+		RenewBefore: 10 * time.Minute, VerifyTimeout: time.Minute, RetryInterval: time.Minute,
+	})
+
+	ch := model.ChannelDTO{ChannelID: "UCaaaaaaaaaaaaaaaaaaaaaa", Category: "tech_en", Enabled: true}
+	ws.Sync([]model.ChannelDTO{ch})
+	if len(requests) != 1 || requests[0].Get("hub.mode") != "subscribe" || requests[0].Get("hub.secret") != "s3cret" {
+		t.Fatalf("unexpected hub requests %v", requests)
+	}
+	if got := len(ws.PollTargets()); got != 1 {
+		t.Fatalf("unverified channel should still be polled, got %d targets", got)
+	}
+	topic := requests[0].Get("hub.topic")
+	if _, ok := ws.Verify("subscribe", "https://www.youtube.com/xml/feeds/videos.xml?channel_id=UCother", "x", 0); ok {
+		t.Fatal("verification for an unknown topic must be rejected")
+	}
+	if challenge, ok := ws.Verify("subscribe", topic, "abc123", time.Hour); !ok || challenge != "abc123" {
+		t.Fatalf("Verify = %q, %v", challenge, ok)
+	}
+	if got := len(ws.PollTargets()); got != 0 {
+		t.Fatalf("verified channel should not be polled, got %d targets", got)
+	}
+
+	body := []byte(pushedFeed)
+	if err := ws.HandlePush(ch.ChannelID, body, "sha1=deadbeef"); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	mac := hmac.New(sha1.New, []byte("s3cret"))
+	mac.Write(body)
+	if err := ws.HandlePush(ch.ChannelID, body, "sha1="+hex.EncodeToString(mac.Sum(nil))); err != nil {
+		t.Fatalf("HandlePush error: %v", err)
+	}
+	if got := atomic.LoadInt32(&posts); got != 1 {
+		t.Fatalf("expected 1 webhook post, got %d", got)
+	}
+	for _, id := range []string{"NEWVIDEO", "GONEVIDEO"} {
+		if seen, _ := notified.Has(id); !seen {
+			t.Fatalf("%s should be recorded in the history", id)
+		}
+	}
+}
+
+func TestWebSubDeletedEntryKeepsChannelIndex(t *testing.T) {
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(t.TempDir(), "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := NewWebSubService(nil, nil, nil, notified, WebSubOptions{})
+	deleted := func(channelID, videoID string) []byte {
+		return []byte(`<feed xmlns:at="http://purl.org/atompub/tombstones/1.0" xmlns="http://www.w3.org/2005/Atom">
+  <at:deleted-entry ref="yt:video:` + videoID + `" when="2024-05-01T11:00:00+00:00">
+    <at:by><name>Example</name><uri>https://www.youtube.com/channel/` + channelID + `</uri></at:by>
+  </at:deleted-entry>
+</feed>`)
+	}
+
+	if err := ws.HandlePush("UCnew", deleted("UCnew", "GONE1"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := notified.Has("GONE1"); !seen {
+		t.Fatal("a deleted video should be recorded so that it is never notified")
+	}
+	if has, _ := notified.HasChannel("UCnew"); has {
+		t.Fatal("a tombstone must not make a channel count as seeded")
+	}
+
+	known := model.NotifiedRecord{VideoID: "KNOWN", ChannelID: "UCold", PublishedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), NotifiedAt: time.Now()}
+	if err := notified.Append(known); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.HandlePush("UCold", deleted("UCold", "GONE2"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.HandlePush("UCold", deleted("UCold", "KNOWN"), ""); err != nil {
+		t.Fatal(err)
+	}
+	latest, ok, _ := notified.LatestForChannel("UCold")
+	if !ok || latest.VideoID != "KNOWN" || !latest.PublishedAt.Equal(known.PublishedAt) {
+		t.Fatalf("the upload cursor should stay on the newest fetched video, got %+v", latest)
+	}
+	if all, _ := notified.ListAll(); len(all) != 3 {
+		t.Fatalf("a deleted video already in the history should not be appended again, got %+v", all)
+	}
+}
+
+func TestWebSubVerificationBeforeHubResponds(t *testing.T) {
+	var (
+		ws       WebSubService
+		verified bool
+	)
+	hubSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		// Verify the intent before answering the subscription request, as the spec allows.
+		_, verified = ws.Verify("subscribe", r.PostForm.Get("hub.topic"), "abc", time.Hour)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hubSrv.Close()
+	ws = NewWebSubService(&repository.HTTPWebSubHub{HubURL: hubSrv.URL}, nil, nil, nil, WebSubOptions{
+		Callback: "https://example.test/websub", Secret: "s3cret", Lease: time.Hour,
+		RenewBefore: 10 * time.Minute, VerifyTimeout: time.Minute, RetryInterval: time.Minute,
+	})
+
+	ws.Sync([]model.ChannelDTO{{ChannelID: "UCaaaaaaaaaaaaaaaaaaaaaa", Enabled: true}})
+	if !verified {
+		t.Fatal("a verification sent while the subscribe request is in flight should be accepted")
+	}
+	if got := len(ws.PollTargets()); got != 0 {
+		t.Fatalf("verified channel should not be polled, got %d targets", got)
+	}
+}
+
+func TestWebSubIgnoresPushedUpdatesOfOldVideos(t *testing.T) {
+	var posts int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+	hubSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hubSrv.Close()
+
+	dir := t.TempDir()
+	notified, err := repository.NewCSVNotifiedRepository(filepath.Join(dir, "notified.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := repository.NewJSONLOutboxRepository(filepath.Join(dir, "outbox.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	feedSvc := NewFeedService(nil, nil, notified, nil, FeedOptions{IncludeShorts: true})
+	notifySvc := NewNotifyService(notified, outbox, map[string]Destination{"tech_en": {Output: "discord", Webhook: webhook.URL}}, 0, nil)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ws := NewWebSubService(&repository.HTTPWebSubHub{HubURL: hubSrv.URL}, feedSvc, notifySvc, notified, WebSubOptions{
+		Callback: "https://example.test/websub", Lease: time.Hour, VerifyTimeout: time.Minute, PushMaxAge: 72 * time.Hour,
+	}).(*websubService)
+	ws.now = func() time.Time { return now }
+	channels := []model.ChannelDTO{{ChannelID: "UCaaaaaaaaaaaaaaaaaaaaaa", Category: "tech_en", Enabled: true}, {ChannelID: "UCbbbbbbbbbbbbbbbbbbbbbb", Category: "tech_en", Enabled: true}}
+	ws.Sync(channels)
+
+	push := func(channelID, videoID string, published time.Time) {
+		t.Helper()
+		body := `<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom"><entry>
+  <yt:videoId>` + videoID + `</yt:videoId><yt:channelId>` + channelID + `</yt:channelId><title>edited</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=` + videoID + `"/>
+  <published>` + published.Format(time.RFC3339) + `</published><updated>` + now.Format(time.RFC3339) + `</updated>
+</entry></feed>`
+		if err := ws.HandlePush(channelID, []byte(body), ""); err != nil {
+			t.Fatalf("HandlePush error: %v", err)
+		}
+	}
+
+	// UCaaa has history: anything published before its newest known video is an edit.
+	if err := notified.Append(model.NotifiedRecord{VideoID: "KNOWN", ChannelID: channels[0].ChannelID, PublishedAt: now.Add(-2 * time.Hour), NotifiedAt: now.Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	push(channels[0].ChannelID, "BACKCATALOG", now.Add(-5*time.Hour))
+	// UCbbb has none: the push max age applies.
+	push(channels[1].ChannelID, "ANCIENT", now.Add(-365*24*time.Hour))
+	if got := atomic.LoadInt32(&posts); got != 0 {
+		t.Fatalf("updates of old videos must not be notified, got %d posts", got)
+	}
+	for _, id := range []string{"BACKCATALOG", "ANCIENT"} {
+		if seen, _ := notified.Has(id); seen {
+			t.Fatalf("%s should be left alone, not recorded", id)
+		}
+	}
+
+	push(channels[0].ChannelID, "UPLOAD", now.Add(-time.Minute))
+	if got := atomic.LoadInt32(&posts); got != 1 {
+		t.Fatalf("a new upload should still be notified, got %d posts", got)
+	}
+}